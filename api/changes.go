@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/logging"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+type changesResponse struct {
+	Changes []database.Change `json:"changes"`
+	// Next is the since value of the following page. It equals the
+	// requested one when there are no new changes.
+	Next string `json:"next"`
+}
+
+func APIChanges(w http.ResponseWriter, r *http.Request) {
+	logging.LoggerMiddleware(http.HandlerFunc(changesHandler), nil).ServeHTTP(w, r)
+}
+
+func changesHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := database.ParseChangeCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: invalid since: %s", err)
+		return
+	}
+
+	limit := defaultChangesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxChangesLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Error: limit must be between 1 and %d", maxChangesLimit)
+			return
+		}
+	}
+
+	db, err := database.DB()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+	defer db.Close()
+
+	changes, err := database.GetChanges(r.Context(), db, since, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	resp := changesResponse{Changes: changes, Next: since.String()}
+	if len(changes) > 0 {
+		resp.Next = changes[len(changes)-1].Cursor().String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/jobs"
 	"github.com/lsmoura/omdb-api/logging"
 	"github.com/lsmoura/omdb-api/omdb"
 	"net/http"
@@ -37,12 +40,40 @@ func importAllMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	if err := omdb.ImportAllMovies(r.Context(), db); err != nil {
+	missingPolicy, err := omdb.ParseMissingPolicy(r.URL.Query().Get("missing"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	params := jobs.Params{MissingPolicy: missingPolicy}
+
+	if err := params.SetBudget(r.URL.Query().Get("budget")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	id, err := jobs.Enqueue(r.Context(), db, "all_movies", params)
+	if err != nil {
+		if errors.Is(err, omdb.ErrImportRunning) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, "Error: %s", err)
+			return
+		}
+
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Error: %s", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "OK")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/import-job?id=%d", id))
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]int64{"job_id": id}); err != nil {
+		if logger := logging.LoggerFromContext(r.Context()); logger != nil {
+			logger.Error("json.Encode", "error", err)
+		}
+	}
 }
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/jobs"
+	"github.com/lsmoura/omdb-api/logging"
+	"github.com/lsmoura/omdb-api/omdb"
+	"net/http"
+	"os"
+)
+
+func APIImportAll(w http.ResponseWriter, r *http.Request) {
+	// protect the endpoint with a secret
+	requiredSecret := os.Getenv("OMDB_SECRET")
+	if requiredSecret == "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: OMDB_SECRET not set")
+		return
+	}
+
+	auth := r.URL.Query().Get("auth")
+	if auth != requiredSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "Error: unauthorized")
+		return
+	}
+
+	logging.LoggerMiddleware(http.HandlerFunc(importAllHandler), nil).ServeHTTP(w, r)
+}
+
+// enqueueImportAll enqueues the job of importAllHandler. The tests replace
+// it to do without a database.
+var enqueueImportAll = func(ctx context.Context, db *sql.DB, params jobs.Params) (int64, error) {
+	return jobs.Enqueue(ctx, db, jobs.AllDatasets, params)
+}
+
+func importAllHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := database.DB()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+	defer db.Close()
+
+	missingPolicy, err := omdb.ParseMissingPolicy(r.URL.Query().Get("missing"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	params := jobs.Params{MissingPolicy: missingPolicy}
+
+	if err := params.SetBudget(r.URL.Query().Get("budget")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	id, err := enqueueImportAll(r.Context(), db, params)
+	if err != nil {
+		if errors.Is(err, omdb.ErrImportRunning) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, "Error: %s", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/import-job?id=%d", id))
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]int64{"job_id": id}); err != nil {
+		if logger := logging.LoggerFromContext(r.Context()); logger != nil {
+			logger.Error("json.Encode", "error", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lsmoura/omdb-api/jobs"
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImportAllConflict(t *testing.T) {
+	// the database is never reached
+	t.Setenv("DATABASE_URL", "postgres://localhost/omdb")
+
+	enqueue := enqueueImportAll
+	t.Cleanup(func() { enqueueImportAll = enqueue })
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: http.StatusAccepted},
+		{err: fmt.Errorf("%s: %w", jobs.AllDatasets, omdb.ErrImportRunning), want: http.StatusConflict},
+		{err: fmt.Errorf("db down"), want: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		enqueueImportAll = func(context.Context, *sql.DB, jobs.Params) (int64, error) {
+			if test.err != nil {
+				return 0, test.err
+			}
+			return 42, nil
+		}
+
+		w := httptest.NewRecorder()
+		importAllHandler(w, httptest.NewRequest("POST", "/api/import-all", nil))
+
+		assert.Equal(t, test.want, w.Code, "err=%v", test.err)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/jobs"
+	"github.com/lsmoura/omdb-api/logging"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+func APIImportJob(w http.ResponseWriter, r *http.Request) {
+	// protect the endpoint with a secret
+	requiredSecret := os.Getenv("OMDB_SECRET")
+	if requiredSecret == "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: OMDB_SECRET not set")
+		return
+	}
+
+	auth := r.URL.Query().Get("auth")
+	if auth != requiredSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "Error: unauthorized")
+		return
+	}
+
+	logging.LoggerMiddleware(http.HandlerFunc(importJobHandler), nil).ServeHTTP(w, r)
+}
+
+func importJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: invalid id: %s", err)
+		return
+	}
+
+	db, err := database.DB()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+	defer db.Close()
+
+	job, err := jobs.Get(r.Context(), db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+}
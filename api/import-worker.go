@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/jobs"
+	"github.com/lsmoura/omdb-api/logging"
+	"net/http"
+	"os"
+)
+
+// APIImportWorker runs the next queued import job, for deployments without
+// an omdbctl worker running next to them. It answers 204 when there was
+// nothing to run.
+//
+// The job runs within the request, so it only claims one per call, and the
+// cron in vercel.base.json calls it every few minutes. A job killed by the
+// time limit of the function is picked up again once it goes stale, and
+// fails the same way: a full import of all_movies or of every dataset does
+// not fit in a function call, so deployments importing those need omdbctl
+// worker running somewhere.
+func APIImportWorker(w http.ResponseWriter, r *http.Request) {
+	// protect the endpoint with a secret
+	requiredSecret := os.Getenv("OMDB_SECRET")
+	if requiredSecret == "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: OMDB_SECRET not set")
+		return
+	}
+
+	auth := r.URL.Query().Get("auth")
+	if auth != requiredSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "Error: unauthorized")
+		return
+	}
+
+	logging.LoggerMiddleware(http.HandlerFunc(importWorkerHandler), nil).ServeHTTP(w, r)
+}
+
+func importWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := database.DB()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+	defer db.Close()
+
+	found, err := jobs.RunOnce(r.Context(), db)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error: %s", err)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "OK")
+}
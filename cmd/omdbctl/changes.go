@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"os"
+)
+
+// runChanges prints the recorded catalog changes as JSON lines, paging
+// through them until there are none left.
+func runChanges(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("changes", flag.ContinueOnError)
+	since := fs.String("since", "", "only print changes after this cursor, as \"<txid>-<id>\"")
+	pageSize := fs.Int("page-size", 1000, "number of changes read at a time")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("fs.Parse: %w", err)
+	}
+	if *pageSize < 1 {
+		return fmt.Errorf("-page-size must be positive")
+	}
+
+	next, err := database.ParseChangeCursor(*since)
+	if err != nil {
+		return fmt.Errorf("database.ParseChangeCursor: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		changes, err := database.GetChanges(ctx, db, next, *pageSize)
+		if err != nil {
+			return fmt.Errorf("database.GetChanges: %w", err)
+		}
+
+		for _, c := range changes {
+			if err := enc.Encode(c); err != nil {
+				return fmt.Errorf("enc.Encode: %w", err)
+			}
+			next = c.Cursor()
+		}
+
+		if len(changes) < *pageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/export"
+	"github.com/lsmoura/omdb-api/omdb"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFile writes path through a temporary file, so a failed export never
+// leaves a truncated file behind.
+func writeFile(path string, fn func(f *os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := fn(f); err != nil {
+		return err
+	}
+
+	// temporary files are only readable by their owner
+	if err := f.Chmod(0644); err != nil {
+		return fmt.Errorf("f.Chmod: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return nil
+}
+
+// exportTables parses the dataset arguments of the export commands, which
+// default to every table.
+func exportTables(names []string) ([]export.Table, error) {
+	if len(names) == 0 {
+		return export.Tables, nil
+	}
+
+	tables := make([]export.Table, len(names))
+	for i, name := range names {
+		t, ok := export.LookupTable(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown dataset: %q", name)
+		}
+		tables[i] = t
+	}
+
+	return tables, nil
+}
+
+// sourceFlags registers the flags choosing where exports read from, and
+// returns a function building the source once the flags are parsed.
+func sourceFlags(fs *flag.FlagSet) func() (export.Source, error) {
+	from := fs.String("from", "postgres", "where to read the catalog from: postgres or dumps")
+	dumps := fs.String("dumps", "", "directory holding <dataset>.csv.bz2 dumps for -from dumps, downloaded from omdb when empty")
+
+	return func() (export.Source, error) {
+		switch *from {
+		case "postgres":
+			db, err := database.DB()
+			if err != nil {
+				return nil, fmt.Errorf("database.DB: %w", err)
+			}
+			return export.DatabaseSource(db), nil
+		case "dumps":
+			return export.DumpSource(func(ctx context.Context, d omdb.Dataset) (io.ReadCloser, error) {
+				if *dumps != "" {
+					return os.Open(filepath.Join(*dumps, d.Name+".csv.bz2"))
+				}
+				return omdb.NewFetcher().Fetch(ctx, d.URL)
+			}), nil
+		default:
+			return nil, fmt.Errorf("unknown source: %q", *from)
+		}
+	}
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to write the dumps to")
+	compressionName := fs.String("compression", string(export.Bzip2), "compression of the dumps: bzip2, gzip or none")
+	format := fs.String("format", "csv", "format of the dumps: csv, ndjson or parquet")
+	rowGroupSize := fs.Int64("row-group-size", export.DefaultParquetRowGroupSize, "maximum number of rows per parquet row group")
+	source := sourceFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("fs.Parse: %w", err)
+	}
+
+	compression, err := export.ParseCompression(*compressionName)
+	if err != nil {
+		return fmt.Errorf("export.ParseCompression: %w", err)
+	}
+
+	write := export.WriteCSV
+	switch *format {
+	case "csv":
+	case "ndjson":
+		write = export.WriteNDJSON
+	case "parquet":
+		// parquet compresses its pages itself
+		compressionSet := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "compression" {
+				compressionSet = true
+			}
+		})
+		if compressionSet {
+			return fmt.Errorf("-compression does not apply to parquet")
+		}
+		compression = export.None
+
+		opts := export.ParquetOptions{RowGroupSize: *rowGroupSize}
+		write = func(ctx context.Context, src export.Source, t export.Table, w io.Writer, _ export.Compression) error {
+			return export.WriteParquet(ctx, src, t, w, opts)
+		}
+	default:
+		return fmt.Errorf("unknown format: %q", *format)
+	}
+
+	tables, err := exportTables(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	src, err := source()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		path := filepath.Join(*dir, t.Dataset+"."+*format+compression.Extension())
+		err := writeFile(path, func(f *os.File) error {
+			return write(ctx, src, t, f, compression)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Dataset, err)
+		}
+	}
+
+	return nil
+}
+
+func runExportSQLite(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export-sqlite", flag.ContinueOnError)
+	out := fs.String("out", "omdb.sqlite", "path of the SQLite database to write")
+	source := sourceFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("fs.Parse: %w", err)
+	}
+
+	src, err := source()
+	if err != nil {
+		return err
+	}
+
+	// SQLite wants a path, so build the database next to the target and
+	// move it in place once complete
+	tmp := filepath.Join(filepath.Dir(*out), "."+filepath.Base(*out)+".tmp")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	if err := export.WriteSQLite(ctx, tmp, src); err != nil {
+		return fmt.Errorf("export.WriteSQLite: %w", err)
+	}
+
+	if err := os.Rename(tmp, *out); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/lsmoura/omdb-api/omdb"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// datasetName guesses the name of a dataset from the file name of its dump,
+// like all_movies for all_movies.csv.bz2.
+func datasetName(source string) string {
+	name := path.Base(source)
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+// runInspect profiles a dump, local or remote, to help adding a dataset.
+func runInspect(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	dialect := fs.String("dialect", "omdb", "dialect of the dump: omdb, mysql, rfc4180 or tsv")
+	samples := fs.Int("samples", 5, "number of sample rows to print")
+	name := fs.String("name", "", "name of the dataset, guessed from the file name by default")
+	createTable := fs.Bool("sql", false, "print a draft CREATE TABLE statement")
+	descriptor := fs.Bool("descriptor", false, "print a draft entry for omdb.Datasets")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("fs.Parse: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single file or URL")
+	}
+	source := fs.Arg(0)
+	if *name == "" {
+		*name = datasetName(source)
+	}
+
+	inputDialect, err := csv.ParseDialect(*dialect)
+	if err != nil {
+		return fmt.Errorf("csv.ParseDialect: %w", err)
+	}
+
+	var input io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		input, err = omdb.NewFetcher().Fetch(ctx, source)
+		if err != nil {
+			return fmt.Errorf("fetcher.Fetch: %w", err)
+		}
+	} else {
+		input, err = os.Open(source)
+		if err != nil {
+			return fmt.Errorf("os.Open: %w", err)
+		}
+	}
+	defer input.Close()
+
+	profile, err := omdb.InspectDump(ctx, input, inputDialect, *samples)
+	if err != nil {
+		return fmt.Errorf("omdb.InspectDump: %w", err)
+	}
+
+	fmt.Printf("%s: %d rows, %d malformed\n\n", *name, profile.Rows, profile.Malformed)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLUMN\tTYPE\tNULL\tEMPTY\tMAX LENGTH\tDISTINCT")
+	for _, c := range profile.Columns {
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%d\t%d\t~%d\n", c.Name, c.Type, 100*profile.NullRatio(c), c.Empty, c.MaxLength, c.Distinct)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("tw.Flush: %w", err)
+	}
+
+	if len(profile.Samples) > 0 {
+		fmt.Println()
+		w := csv.NewWriter(os.Stdout)
+		w.Dialect = inputDialect
+		for _, fields := range profile.Samples {
+			if err := w.WriteFields(fields); err != nil {
+				return fmt.Errorf("w.WriteFields: %w", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("w.Flush: %w", err)
+		}
+	}
+
+	if *createTable {
+		fmt.Println()
+		fmt.Print(profile.CreateTable(*name))
+	}
+	if *descriptor {
+		url := source
+		if !strings.Contains(url, "://") {
+			url = "http://www.omdb.org/data/" + path.Base(source)
+		}
+		fmt.Println()
+		fmt.Print(profile.Descriptor(*name, url))
+	}
+
+	return nil
+}
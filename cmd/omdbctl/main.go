@@ -2,38 +2,239 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
 	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/jobs"
 	"github.com/lsmoura/omdb-api/logging"
 	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/lsmoura/omdb-api/scheduler"
 	"golang.org/x/exp/slog"
 	"os"
 	"os/signal"
+	"runtime"
+	"time"
 )
 
+// importFlags parses the flags shared by every import command.
+func importFlags(fs *flag.FlagSet, args []string) ([]omdb.ImportOption, error) {
+	lenient := fs.Bool("lenient", false, "quarantine malformed rows in import_rejects instead of aborting")
+	budget := fs.Int("budget", 100, "maximum number of rejected rows in lenient mode, negative for no limit")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "number of goroutines parsing rows")
+	progress := fs.Bool("progress", false, "draw a progress bar on stderr")
+	wait := fs.Bool("wait", false, "wait for a running import of the same dataset instead of failing")
+	strictHeader := fs.Bool("strict-header", false, "fail when the header of the dump differs from the expected columns, instead of mapping them by name")
+	minRows := fs.Int("min-rows", omdb.DefaultIntegrityChecks.MinRows, "minimum number of rows the dump must have")
+	maxShrink := fs.Float64("max-shrink", omdb.DefaultIntegrityChecks.MaxShrinkPercent, "maximum percentage the table may shrink by, negative to disable")
+	checksum := fs.String("sha256", "", "expected sha256 of the compressed dump")
+	file := fs.String("file", "", "import from a local file instead of downloading the dump")
+	format := fs.String("format", string(omdb.FormatCSV), "format of the input: csv or ndjson")
+	dialect := fs.String("dialect", "omdb", "dialect of csv input: omdb, mysql, rfc4180 or tsv")
+	normalize := fs.String("normalize", string(omdb.NormalizeOff), "what to do with text that is not NFC or not valid UTF-8: off, repair or reject")
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("fs.Parse: %w", err)
+	}
+
+	opts := []omdb.ImportOption{
+		omdb.WithWorkers(*workers),
+		omdb.WithIntegrityChecks(omdb.IntegrityChecks{
+			MinRows:          *minRows,
+			MaxShrinkPercent: *maxShrink,
+			SHA256:           *checksum,
+		}),
+	}
+	inputFormat, err := omdb.ParseFormat(*format)
+	if err != nil {
+		return nil, fmt.Errorf("omdb.ParseFormat: %w", err)
+	}
+	opts = append(opts, omdb.WithFormat(inputFormat))
+
+	inputDialect, err := csv.ParseDialect(*dialect)
+	if err != nil {
+		return nil, fmt.Errorf("csv.ParseDialect: %w", err)
+	}
+	opts = append(opts, omdb.WithDialect(inputDialect))
+
+	normalization, err := omdb.ParseNormalization(*normalize)
+	if err != nil {
+		return nil, fmt.Errorf("omdb.ParseNormalization: %w", err)
+	}
+	opts = append(opts, omdb.WithNormalization(normalization))
+
+	if *file != "" {
+		opts = append(opts, omdb.WithFile(*file))
+	}
+	if *lenient {
+		opts = append(opts, omdb.WithLenient(*budget))
+	}
+	if *wait {
+		opts = append(opts, omdb.WithWaitForLock())
+	}
+	if *strictHeader {
+		opts = append(opts, omdb.WithStrictHeader())
+	}
+	if *progress {
+		opts = append(opts, omdb.WithProgress(progressBar(os.Stderr), 250*time.Millisecond))
+	}
+
+	return opts, nil
+}
+
 func run(ctx context.Context, args []string) error {
 	if len(args) == 1 {
 		return fmt.Errorf("%s: missing command", args[0])
 	}
 
+	// commands that can run without a database
+	switch args[1] {
+	case "help":
+		fmt.Println("Available commands:")
+		fmt.Println("  migrate")
+		fmt.Println("  import-all-movies [-missing keep|soft-delete|hard-delete] [-lenient] [-budget n] [-workers n] [-progress] [-wait] [-strict-header] [-normalize off|repair|reject] [-min-rows n] [-max-shrink pct] [-sha256 sum] [-file path] [-format csv|ndjson] [-dialect omdb|mysql|rfc4180|tsv]")
+		fmt.Println("  import-movie-links [-lenient] [-budget n] [-workers n] [-progress] [-wait] [-strict-header] [-normalize off|repair|reject] [-min-rows n] [-max-shrink pct] [-sha256 sum] [-file path] [-format csv|ndjson] [-dialect omdb|mysql|rfc4180|tsv]")
+		fmt.Println("  import-all [-missing keep|soft-delete|hard-delete] [-lenient] [-budget n] [-workers n] [-progress] [-wait] [-strict-header] [-normalize off|repair|reject] [-min-rows n] [-max-shrink pct]")
+		fmt.Println("  enqueue <dataset|all>")
+		fmt.Println("  worker [-poll duration] [-once]")
+		fmt.Println("  scheduler [-config file]")
+		fmt.Println("  changes [-since txid-id] [-page-size n]")
+		fmt.Println("  export [-dir path] [-format csv|ndjson|parquet] [-compression bzip2|gzip|none] [-row-group-size rows] [-from postgres|dumps] [-dumps dir] [dataset...]")
+		fmt.Println("  export-sqlite [-out path] [-from postgres|dumps] [-dumps dir]")
+		fmt.Println("  inspect [-dialect omdb|mysql|rfc4180|tsv] [-samples n] [-name dataset] [-sql] [-descriptor] <file|url>")
+		return nil
+	case "export":
+		if err := runExport(ctx, args[2:]); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		return nil
+	case "export-sqlite":
+		if err := runExportSQLite(ctx, args[2:]); err != nil {
+			return fmt.Errorf("export-sqlite: %w", err)
+		}
+		return nil
+	case "inspect":
+		if err := runInspect(ctx, args[2:]); err != nil {
+			return fmt.Errorf("inspect: %w", err)
+		}
+		return nil
+	}
+
 	db, err := database.DB()
 	if err != nil {
 		return fmt.Errorf("database.DB: %w", err)
 	}
 
 	switch args[1] {
-	case "help":
-		fmt.Println("Available commands:")
-		fmt.Println("  import-all-movies")
-		fmt.Println("  import-movie-links")
+	case "migrate":
+		if err := database.Migrate(ctx, db); err != nil {
+			return fmt.Errorf("database.Migrate: %w", err)
+		}
 	case "import-all-movies":
-		if err := omdb.ImportAllMovies(ctx, db); err != nil {
+		fs := flag.NewFlagSet(args[1], flag.ContinueOnError)
+		missing := fs.String("missing", string(omdb.MissingKeep), "what to do with movies missing from the dump: keep, soft-delete or hard-delete")
+		opts, err := importFlags(fs, args[2:])
+		if err != nil {
+			return err
+		}
+
+		missingPolicy, err := omdb.ParseMissingPolicy(*missing)
+		if err != nil {
+			return fmt.Errorf("omdb.ParseMissingPolicy: %w", err)
+		}
+		opts = append(opts, omdb.WithMissingPolicy(missingPolicy))
+
+		if err := omdb.ImportAllMovies(ctx, db, opts...); err != nil {
 			return fmt.Errorf("omdb.ImportAllMovies: %w", err)
 		}
 	case "import-movie-links":
-		if err := omdb.ImportMovieLinks(ctx, db); err != nil {
+		opts, err := importFlags(flag.NewFlagSet(args[1], flag.ContinueOnError), args[2:])
+		if err != nil {
+			return err
+		}
+
+		if err := omdb.ImportMovieLinks(ctx, db, opts...); err != nil {
 			return fmt.Errorf("omdb.ImportMovieLinks: %w", err)
 		}
+	case "import-all":
+		fs := flag.NewFlagSet(args[1], flag.ContinueOnError)
+		missing := fs.String("missing", string(omdb.MissingKeep), "what to do with movies missing from the dump: keep, soft-delete or hard-delete")
+		opts, err := importFlags(fs, args[2:])
+		if err != nil {
+			return err
+		}
+
+		// every dataset has its own input, so flags describing one input
+		// cannot apply to all of them
+		var unsupported string
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "file", "sha256", "format", "dialect":
+				if unsupported == "" {
+					unsupported = f.Name
+				}
+			}
+		})
+		if unsupported != "" {
+			return fmt.Errorf("%s: -%s is not supported", args[1], unsupported)
+		}
+
+		missingPolicy, err := omdb.ParseMissingPolicy(*missing)
+		if err != nil {
+			return fmt.Errorf("omdb.ParseMissingPolicy: %w", err)
+		}
+		opts = append(opts, omdb.WithMissingPolicy(missingPolicy))
+
+		if err := omdb.ImportAll(ctx, db, opts...); err != nil {
+			return fmt.Errorf("omdb.ImportAll: %w", err)
+		}
+	case "enqueue":
+		if len(args) < 3 {
+			return fmt.Errorf("%s: missing dataset", args[1])
+		}
+
+		id, err := jobs.Enqueue(ctx, db, args[2], jobs.Params{})
+		if err != nil {
+			return fmt.Errorf("jobs.Enqueue: %w", err)
+		}
+		fmt.Println(id)
+	case "worker":
+		fs := flag.NewFlagSet(args[1], flag.ContinueOnError)
+		poll := fs.Duration("poll", 30*time.Second, "how often to look for new jobs")
+		once := fs.Bool("once", false, "exit once the queue is empty")
+		if err := fs.Parse(args[2:]); err != nil {
+			return fmt.Errorf("fs.Parse: %w", err)
+		}
+
+		if *once {
+			if err := jobs.Drain(ctx, db); err != nil {
+				return fmt.Errorf("jobs.Drain: %w", err)
+			}
+			return nil
+		}
+
+		if err := jobs.Work(ctx, db, *poll); err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("jobs.Work: %w", err)
+		}
+	case "changes":
+		if err := runChanges(ctx, db, args[2:]); err != nil {
+			return fmt.Errorf("changes: %w", err)
+		}
+	case "scheduler":
+		fs := flag.NewFlagSet(args[1], flag.ContinueOnError)
+		configPath := fs.String("config", "scheduler.json", "path to the scheduler config")
+		if err := fs.Parse(args[2:]); err != nil {
+			return fmt.Errorf("fs.Parse: %w", err)
+		}
+
+		config, err := scheduler.LoadConfig(*configPath)
+		if err != nil {
+			return fmt.Errorf("scheduler.LoadConfig: %w", err)
+		}
+
+		if err := scheduler.Run(ctx, db, config); err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("scheduler.Run: %w", err)
+		}
 	default:
 		return fmt.Errorf("%s: unknown command", args[1])
 	}
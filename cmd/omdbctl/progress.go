@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/lsmoura/omdb-api/omdb"
+	"io"
+	"strings"
+	"time"
+)
+
+const progressBarWidth = 30
+
+// progressBar draws import progress on a single terminal line.
+func progressBar(w io.Writer) omdb.ProgressFunc {
+	return func(p omdb.Progress) {
+		var bar string
+		if fraction := p.Fraction(); fraction >= 0 {
+			filled := int(fraction * progressBarWidth)
+			if filled > progressBarWidth {
+				filled = progressBarWidth
+			}
+			bar = fmt.Sprintf(
+				"[%s%s] %5.1f%% %s/%s",
+				strings.Repeat("=", filled),
+				strings.Repeat(" ", progressBarWidth-filled),
+				fraction*100,
+				formatBytes(p.BytesRead),
+				formatBytes(p.TotalBytes),
+			)
+		} else {
+			bar = formatBytes(p.BytesRead)
+		}
+
+		line := fmt.Sprintf("\r\x1b[2K%s: %s, %d rows (%.0f/s)", p.Dataset, bar, p.Rows, p.RowsPerSecond)
+		if p.Rejected > 0 {
+			line += fmt.Sprintf(", %d rejected", p.Rejected)
+		}
+		if p.ETA > 0 {
+			line += fmt.Sprintf(", ETA %s", p.ETA.Round(time.Second))
+		}
+		if p.Done {
+			line += "\n"
+		}
+
+		fmt.Fprint(w, line)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
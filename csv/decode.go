@@ -0,0 +1,344 @@
+package csv
+
+import (
+	"database/sql"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Null is how omdb dumps write a NULL value.
+const Null = `\N`
+
+// DefaultDateLayout is the layout time.Time fields are parsed with, unless
+// their tag sets another one.
+const DefaultDateLayout = "2006-01-02"
+
+var (
+	// ErrFieldCount is reported for a record with a different number of
+	// fields than there are columns.
+	ErrFieldCount = errors.New("wrong number of fields")
+	// ErrMissingColumn is reported for a struct field whose column does
+	// not exist.
+	ErrMissingColumn = errors.New("missing column")
+)
+
+// Unmarshaler is implemented by types that parse a field themselves. They
+// are handed the field as is, including the NULL marker.
+type Unmarshaler interface {
+	UnmarshalCSV(field string) error
+}
+
+// DecodeError tells which field of a record could not be decoded. Line and
+// Column are only set when decoding through a Decoder.
+type DecodeError struct {
+	Line   int
+	Column int
+	// Field is the index of the field in the record, or -1 when the error
+	// is about the record as a whole.
+	Field int
+	// Name is the column name of the field, if known.
+	Name string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	}
+	if e.Name != "" {
+		b.WriteString(e.Name + ": ")
+	} else if e.Field >= 0 {
+		fmt.Fprintf(&b, "field %d: ", e.Field)
+	}
+	b.WriteString(e.Err.Error())
+
+	return b.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// structField is a struct field bound to a column, either by name or by
+// position.
+type structField struct {
+	index    []int
+	name     string
+	position int
+	layout   string
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+// structFields parses the csv tags of t. A tag holds the column name of the
+// field, or its 0-based position when it is a number, optionally followed
+// by ",format=<layout>" for time.Time fields. Fields tagged "-" and
+// unexported ones are skipped, and untagged ones use their own name.
+func structFields(t reflect.Type) ([]structField, error) {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField), nil
+	}
+
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		field := structField{index: f.Index, name: name, position: -1, layout: DefaultDateLayout}
+		if position, err := strconv.Atoi(name); err == nil {
+			field.name = ""
+			field.position = position
+		}
+
+		for _, option := range strings.Split(options, ",") {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "":
+			case "format":
+				field.layout = value
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown tag option %q", t, f.Name, key)
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	structFieldsCache.Store(t, fields)
+
+	return fields, nil
+}
+
+// Unmarshal is like UnmarshalFields, for text fields where \N is NULL.
+func Unmarshal(columns []string, fields []string, v any) error {
+	return UnmarshalFields(columns, Fields(fields), v)
+}
+
+// UnmarshalFields stores the fields of a record in the struct v points to,
+// matching struct fields to the given column names through their csv tags.
+// Columns may be nil when every struct field is bound by position.
+//
+// Besides strings, numbers, booleans and time.Time, fields can be
+// pointers, sql.Null types, Unmarshaler or encoding.TextUnmarshaler
+// implementations. Pointers and sql.Null types are left as NULL for a NULL
+// field, and for an empty one unless they hold a string. Other types read a
+// NULL field as an empty one.
+func UnmarshalFields(columns []string, fields []Field, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	if columns != nil && len(fields) != len(columns) {
+		return &DecodeError{Field: -1, Err: fmt.Errorf("%w: expected %d, got %d", ErrFieldCount, len(columns), len(fields))}
+	}
+
+	sf, err := structFields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range sf {
+		position := f.position
+		name := f.name
+		if position < 0 {
+			position = indexOf(columns, f.name)
+			if position < 0 {
+				return &DecodeError{Field: -1, Name: f.name, Err: ErrMissingColumn}
+			}
+		} else if position < len(columns) {
+			name = columns[position]
+		}
+
+		if position >= len(fields) {
+			return &DecodeError{Field: position, Name: name, Err: fmt.Errorf("%w: no field %d", ErrFieldCount, position)}
+		}
+
+		if err := setField(rv.FieldByIndex(f.index), fields[position], f.layout); err != nil {
+			return &DecodeError{Field: position, Name: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func indexOf(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+var (
+	nullTimeType   = reflect.TypeOf(sql.NullTime{})
+	nullStringType = reflect.TypeOf(sql.NullString{})
+)
+
+// isNull tells whether field is NULL for a nullable value of type t.
+func isNull(field Field, t reflect.Type) bool {
+	if field.Null {
+		return true
+	}
+
+	return field.Value == "" && t.Kind() != reflect.String
+}
+
+func setField(v reflect.Value, f Field, layout string) error {
+	if v.Kind() == reflect.Pointer {
+		if isNull(f, v.Type().Elem()) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		p := reflect.New(v.Type().Elem())
+		if err := setField(p.Elem(), f, layout); err != nil {
+			return err
+		}
+		v.Set(p)
+
+		return nil
+	}
+
+	field := f.Value
+	switch u := v.Addr().Interface().(type) {
+	case Unmarshaler:
+		if f.Null {
+			return u.UnmarshalCSV(Null)
+		}
+		return u.UnmarshalCSV(field)
+	case *time.Time:
+		t, err := time.Parse(layout, field)
+		if err != nil {
+			return err
+		}
+		*u = t
+
+		return nil
+	case *sql.NullTime:
+		if isNull(f, nullTimeType) {
+			*u = sql.NullTime{}
+			return nil
+		}
+
+		t, err := time.Parse(layout, field)
+		if err != nil {
+			return err
+		}
+		*u = sql.NullTime{Time: t, Valid: true}
+
+		return nil
+	case sql.Scanner:
+		// the string sql.Null types keep empty fields
+		if f.Null || (field == "" && v.Type() != nullStringType) {
+			return u.Scan(nil)
+		}
+		return u.Scan(field)
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText([]byte(field))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(field)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(field, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(field, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(field, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(field)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// Decoder reads records into structs, see Unmarshal.
+type Decoder struct {
+	r       *Reader
+	columns []string
+}
+
+func NewDecoder(r *Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// ReadHeader reads the column names from the next record.
+func (d *Decoder) ReadHeader() ([]string, error) {
+	columns, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	d.columns = columns
+
+	return columns, nil
+}
+
+// SetColumns names the columns of an input without a header.
+func (d *Decoder) SetColumns(columns []string) {
+	d.columns = columns
+}
+
+// Decode reads the next record into the struct v points to. It returns
+// io.EOF once the input is exhausted, and a *DecodeError positioned in the
+// input when a field can not be decoded.
+func (d *Decoder) Decode(v any) error {
+	fields, err := d.r.ReadFields()
+	if err != nil {
+		return err
+	}
+
+	if err := UnmarshalFields(d.columns, fields, v); err != nil {
+		var de *DecodeError
+		if errors.As(err, &de) {
+			de.Line, de.Column = d.r.Line(), 1
+			if de.Field >= 0 && de.Field < len(fields) {
+				de.Line, de.Column = d.r.FieldPos(de.Field)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package csv
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type upper string
+
+func (u *upper) UnmarshalCSV(field string) error {
+	if field == "" {
+		return errors.New("empty")
+	}
+	*u = upper(strings.ToUpper(field))
+	return nil
+}
+
+type movie struct {
+	ID       int64         `csv:"id"`
+	Name     string        `csv:"name"`
+	ParentID *int64        `csv:"parent_id"`
+	Date     sql.NullTime  `csv:"date"`
+	Released *time.Time    `csv:"date,format=2006-01-02"`
+	Kind     upper         `csv:"kind"`
+	Rating   sql.NullInt64 `csv:"rating"`
+	Note     *string       `csv:"note"`
+	Ignored  string        `csv:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	columns := []string{"id", "name", "parent_id", "date", "kind", "rating", "note"}
+
+	var m movie
+	require.NoError(t, Unmarshal(columns, []string{"1", "Foo", "", "1977-05-25", "movie", "7", ""}, &m))
+
+	date := time.Date(1977, 5, 25, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, int64(1), m.ID)
+	assert.Equal(t, "Foo", m.Name)
+	assert.Nil(t, m.ParentID)
+	assert.Equal(t, sql.NullTime{Time: date, Valid: true}, m.Date)
+	require.NotNil(t, m.Released)
+	assert.Equal(t, date, *m.Released)
+	assert.Equal(t, upper("MOVIE"), m.Kind)
+	assert.Equal(t, sql.NullInt64{Int64: 7, Valid: true}, m.Rating)
+	require.NotNil(t, m.Note)
+	assert.Equal(t, "", *m.Note)
+
+	m = movie{}
+	require.NoError(t, Unmarshal(columns, []string{"2", `\N`, "11", `\N`, "serie", `\N`, `\N`}, &m))
+	assert.Equal(t, "", m.Name)
+	require.NotNil(t, m.ParentID)
+	assert.Equal(t, int64(11), *m.ParentID)
+	assert.False(t, m.Date.Valid)
+	assert.Nil(t, m.Released)
+	assert.False(t, m.Rating.Valid)
+	assert.Nil(t, m.Note)
+}
+
+func TestUnmarshalByPosition(t *testing.T) {
+	var row struct {
+		Key     string `csv:"1"`
+		MovieID int    `csv:"2"`
+	}
+	require.NoError(t, Unmarshal(nil, []string{"imdbmovie", "tt0076759", "11", "en"}, &row))
+	assert.Equal(t, "tt0076759", row.Key)
+	assert.Equal(t, 11, row.MovieID)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	columns := []string{"id", "name", "parent_id", "date", "kind", "rating", "note"}
+
+	tests := []struct {
+		fields []string
+		field  int
+		name   string
+		err    error
+	}{
+		{fields: []string{"1", "Foo"}, field: -1, err: ErrFieldCount},
+		{fields: []string{"x", "Foo", "", "", "movie", "", ""}, field: 0, name: "id"},
+		{fields: []string{"1", "Foo", "", "1977-13-01", "movie", "", ""}, field: 3, name: "date"},
+		{fields: []string{"1", "Foo", "", "", "", "", ""}, field: 4, name: "kind"},
+	}
+
+	for _, test := range tests {
+		var m movie
+		err := Unmarshal(columns, test.fields, &m)
+
+		var de *DecodeError
+		require.ErrorAs(t, err, &de, "%q", test.fields)
+		assert.Equal(t, test.field, de.Field, "%q", test.fields)
+		assert.Equal(t, test.name, de.Name, "%q", test.fields)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err)
+		}
+	}
+
+	var m movie
+	err := Unmarshal([]string{"id", "name"}, []string{"1", "Foo"}, &m)
+	assert.ErrorIs(t, err, ErrMissingColumn)
+
+	assert.Error(t, Unmarshal(columns, nil, m))
+}
+
+func TestDecoder(t *testing.T) {
+	input := "id,name,parent_id\n1,\"Foo\",\\N\n2,\"Bar\\\nBaz\",x\n"
+	d := NewDecoder(NewReader(strings.NewReader(input)))
+
+	columns, err := d.ReadHeader()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "parent_id"}, columns)
+
+	type row struct {
+		ID       int    `csv:"id"`
+		Name     string `csv:"name"`
+		ParentID *int   `csv:"parent_id"`
+	}
+
+	var r row
+	require.NoError(t, d.Decode(&r))
+	assert.Equal(t, row{ID: 1, Name: "Foo"}, r)
+
+	err = d.Decode(&r)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	assert.Equal(t, 4, de.Line)
+	assert.Equal(t, 6, de.Column)
+	assert.Equal(t, "parent_id", de.Name)
+	assert.Contains(t, err.Error(), "line 4, column 6: parent_id:")
+
+	assert.Equal(t, io.EOF, d.Decode(&r))
+}
+
+func TestDecoderNull(t *testing.T) {
+	d := NewDecoder(NewReader(strings.NewReader("1,\\N,\\N\n2,\"\\N\",\"\\N\"\n")))
+	d.SetColumns([]string{"id", "name", "date"})
+
+	type row struct {
+		ID   int     `csv:"id"`
+		Name string  `csv:"name"`
+		Date *string `csv:"date"`
+	}
+
+	var r row
+	require.NoError(t, d.Decode(&r))
+	assert.Equal(t, row{ID: 1}, r)
+
+	// a quoted marker is text
+	require.NoError(t, d.Decode(&r))
+	require.NotNil(t, r.Date)
+	assert.Equal(t, `\N`, *r.Date)
+	assert.Equal(t, `\N`, r.Name)
+}
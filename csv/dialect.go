@@ -0,0 +1,194 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EscapeStyle is how a dialect writes characters that would otherwise end
+// a field or a record.
+type EscapeStyle int
+
+const (
+	// EscapeNone relies on quoting alone.
+	EscapeNone EscapeStyle = iota
+	// EscapeBackslash writes \n, \r and \t for newlines, carriage returns
+	// and tabs, and puts a backslash before the delimiter, the quote and
+	// the backslash itself. Any other escaped character keeps its
+	// backslash, so \N reads as is. A backslash ending a line continues
+	// the record on the next one.
+	EscapeBackslash
+)
+
+// Dialect describes a flavour of CSV.
+type Dialect struct {
+	// Delimiter separates the fields of a record.
+	Delimiter byte
+	// Quote starts and ends a quoted field, where the delimiter is not
+	// special. Zero disables quoting.
+	Quote byte
+	// Escape is how the characters that are special to the dialect are
+	// written within a field.
+	Escape EscapeStyle
+	// DoubleQuote reads two quotes within a quoted field as one quote.
+	DoubleQuote bool
+	// Null is how a NULL value is written, if the dialect has a way to.
+	Null string
+}
+
+var (
+	// RFC4180 is CSV as described by RFC 4180: quoted fields may hold
+	// delimiters, newlines and doubled quotes. It has no NULL.
+	RFC4180 = Dialect{Delimiter: ',', Quote: '"', DoubleQuote: true}
+	// MySQL is what MySQL writes with FIELDS TERMINATED BY ',' ENCLOSED
+	// BY '"', and what the omdb dumps use.
+	MySQL = Dialect{Delimiter: ',', Quote: '"', Escape: EscapeBackslash, Null: Null}
+	// TSV is tab separated values, escaped like PostgreSQL's and MySQL's
+	// text formats.
+	TSV = Dialect{Delimiter: '\t', Escape: EscapeBackslash, Null: Null}
+)
+
+// Dialects are the presets by name.
+var Dialects = map[string]Dialect{
+	"rfc4180": RFC4180,
+	"mysql":   MySQL,
+	"omdb":    MySQL,
+	"tsv":     TSV,
+}
+
+func ParseDialect(name string) (Dialect, error) {
+	d, ok := Dialects[strings.ToLower(name)]
+	if !ok {
+		return Dialect{}, fmt.Errorf("unknown dialect: %q", name)
+	}
+
+	return d, nil
+}
+
+// Split splits a record into its unescaped fields, NULL ones included as
+// the Null marker. Errors are *ParseError values.
+func (d Dialect) Split(line string) ([]string, error) {
+	fields, err := d.SplitFields(line)
+	if err != nil {
+		return nil, err
+	}
+
+	return Strings(fields, d.Null), nil
+}
+
+// SplitFields splits a record into its unescaped fields, telling NULL ones
+// apart. Errors are *ParseError values.
+func (d Dialect) SplitFields(line string) ([]Field, error) {
+	fields, _, err := d.split(line)
+	if err != nil {
+		return nil, parseError(1, line, err)
+	}
+
+	return fields, nil
+}
+
+// isNull tells whether raw, a field as written, is the NULL marker.
+func (d Dialect) isNull(raw string) bool {
+	return d.Null != "" && raw == d.Null
+}
+
+// split splits a record into its fields, unescaping them, and also returns
+// the byte offset each field starts at.
+func (d Dialect) split(line string) ([]Field, []int, error) {
+	var fields []Field
+	starts := []int{0}
+
+	var currentField strings.Builder
+	var inQuotes bool
+	var quoteStart int
+	endField := func(end int) {
+		if d.isNull(line[starts[len(starts)-1]:end]) {
+			fields = append(fields, Field{Null: true})
+		} else {
+			fields = append(fields, Field{Value: currentField.String()})
+		}
+		currentField.Reset()
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case d.Quote != 0 && c == d.Quote:
+			if inQuotes && d.DoubleQuote && i+1 < len(line) && line[i+1] == d.Quote {
+				currentField.WriteByte(c)
+				i++
+				continue
+			}
+			if !inQuotes {
+				quoteStart = i
+			}
+			inQuotes = !inQuotes
+		case c == d.Delimiter:
+			if inQuotes {
+				currentField.WriteByte(c)
+				continue
+			}
+			endField(i)
+			starts = append(starts, i+1)
+		case c == '\\' && d.Escape == EscapeBackslash:
+			if i+1 >= len(line) {
+				return nil, nil, &splitError{offset: i, err: ErrTrailingEscape}
+			}
+			i++
+			c = line[i]
+			switch {
+			case c == 'n':
+				currentField.WriteByte('\n')
+			case c == 'r':
+				currentField.WriteByte('\r')
+			case c == 't':
+				currentField.WriteByte('\t')
+			case c == d.Delimiter, c == '\\', d.Quote != 0 && c == d.Quote:
+				currentField.WriteByte(c)
+			default:
+				currentField.WriteByte('\\')
+				currentField.WriteByte(c)
+			}
+		default:
+			currentField.WriteByte(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, nil, &splitError{offset: quoteStart, err: ErrUnterminatedQuote}
+	}
+
+	endField(len(line))
+
+	return fields, starts, nil
+}
+
+// quoteOpen reports whether a quoted field is still open at the end of
+// line, given whether one was open at its start. It is only meaningful for
+// dialects without escapes, where quoted fields may span lines.
+func (d Dialect) quoteOpen(line string, open bool) bool {
+	if d.Quote == 0 {
+		return false
+	}
+
+	// a doubled quote flips twice, so counting them is enough
+	return open != (strings.Count(line, string(d.Quote))%2 == 1)
+}
+
+// needsQuotes tells whether a field must be quoted in a dialect without
+// escapes.
+func (d Dialect) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == d.Delimiter, c == '\n', c == '\r', d.Quote != 0 && c == d.Quote:
+			return true
+		}
+	}
+
+	return field[0] == ' ' || field[len(field)-1] == ' '
+}
@@ -0,0 +1,122 @@
+package csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDialectSplit(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		line    string
+		want    []string
+	}{
+		{
+			dialect: RFC4180,
+			line:    `1,"Sequel, The","say ""hi""",\N,`,
+			want:    []string{"1", "Sequel, The", `say "hi"`, `\N`, ""},
+		},
+		{
+			dialect: RFC4180,
+			line:    "\"multi\r\nline\",x",
+			want:    []string{"multi\r\nline", "x"},
+		},
+		{
+			dialect: MySQL,
+			line:    `1,"Sequel\, The","carriage\rreturn",\N`,
+			want:    []string{"1", "Sequel, The", "carriage\rreturn", `\N`},
+		},
+		{
+			dialect: TSV,
+			line:    "1\tSequel, \"The\"\ttab\\there\t\\N",
+			want:    []string{"1", `Sequel, "The"`, "tab\there", `\N`},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.dialect.Split(test.line)
+		require.NoError(t, err, "%q", test.line)
+		assert.Equal(t, test.want, got, "%q", test.line)
+	}
+
+	_, err := RFC4180.Split(`1,"open`)
+	assert.ErrorIs(t, err, ErrUnterminatedQuote)
+}
+
+func TestDialectSplitFields(t *testing.T) {
+	null := Field{Null: true}
+
+	fields, err := MySQL.SplitFields(`\N,"\N",,"",\\N,\Nx`)
+	require.NoError(t, err)
+	assert.Equal(t, []Field{null, {Value: `\N`}, {}, {}, {Value: `\N`}, {Value: `\Nx`}}, fields)
+
+	fields, err = TSV.SplitFields("\\N\t\\\\N")
+	require.NoError(t, err)
+	assert.Equal(t, []Field{null, {Value: `\N`}}, fields)
+
+	// RFC 4180 has no NULL
+	fields, err = RFC4180.SplitFields(`\N,`)
+	require.NoError(t, err)
+	assert.Equal(t, []Field{{Value: `\N`}, {}}, fields)
+}
+
+func TestReaderRFC4180(t *testing.T) {
+	input := "id,name\r\n1,\"two\r\nlines\"\r\n2,\"quote \"\"\r\n\r\ninside\"\"\"\r\n3,\"open"
+	r := NewReader(strings.NewReader(input))
+	r.Dialect = RFC4180
+
+	want := []struct {
+		fields []string
+		line   int
+	}{
+		{fields: []string{"id", "name"}, line: 1},
+		{fields: []string{"1", "two\r\nlines"}, line: 2},
+		{fields: []string{"2", "quote \"\r\n\r\ninside\""}, line: 4},
+	}
+
+	for _, w := range want {
+		fields, err := r.Read()
+		require.NoError(t, err)
+		assert.Equal(t, w.fields, fields)
+		assert.Equal(t, w.line, r.Line())
+	}
+
+	_, err := r.Read()
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, ErrUnterminatedQuote, pe.Err)
+	assert.Equal(t, 7, pe.Line)
+	assert.Equal(t, 3, pe.Column)
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriterDialects(t *testing.T) {
+	record := []any{int64(1), "Sequel, \"The\"", nil, "tab\tand\nnewline"}
+
+	want := map[string]string{
+		"rfc4180": "1,\"Sequel, \"\"The\"\"\",,\"tab\tand\nnewline\"\n",
+		"mysql":   "1,\"Sequel\\, \\\"The\\\"\",\\N,\"tab\\tand\\nnewline\"\n",
+		"tsv":     "1\tSequel, \"The\"\t\\N\ttab\\tand\\nnewline\n",
+	}
+
+	for name, line := range want {
+		d, err := ParseDialect(name)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Dialect = d
+		require.NoError(t, w.WriteValues(record))
+		require.NoError(t, w.Flush())
+		assert.Equal(t, line, buf.String(), name)
+	}
+
+	_, err := ParseDialect("excel")
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package csv
+
+// Field is a field of a record. A NULL field, the Null marker of the dialect
+// written outside quotes, is told apart from text, be it empty or a quoted
+// marker.
+type Field struct {
+	Value string
+	// Null is set for a NULL field, whose Value is empty.
+	Null bool
+}
+
+// Fields wraps text fields, taking the ones holding the Null marker for
+// NULL, like omdb dumps do.
+func Fields(record []string) []Field {
+	fields := make([]Field, len(record))
+	for i, field := range record {
+		if field == Null {
+			fields[i] = Field{Null: true}
+			continue
+		}
+		fields[i] = Field{Value: field}
+	}
+
+	return fields
+}
+
+// Strings returns the text of fields, with null for the NULL ones.
+func Strings(fields []Field, null string) []string {
+	return appendStrings(make([]string, 0, len(fields)), fields, null)
+}
+
+func appendStrings(record []string, fields []Field, null string) []string {
+	for _, field := range fields {
+		if field.Null {
+			record = append(record, null)
+			continue
+		}
+		record = append(record, field.Value)
+	}
+
+	return record
+}
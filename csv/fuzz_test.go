@@ -0,0 +1,163 @@
+package csv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dumpSnippets are records as they appear in the omdb dumps, along with the
+// odd ones the importers had to deal with.
+var dumpSnippets = []string{
+	`1,"Movie 1",\N,\N`,
+	`500,"Sequel, The",11,"1977-05-25"`,
+	`"TV U600 32\"",Smart TV,32\",,`,
+	`1,"foo",2,"bar",\N,"foo\nbar",foo\nbar,foo\tand\tbar`,
+	`7,"A title\` + "\n" + `spanning lines",\N,\N`,
+	`imdbmovie,tt0076759,11,en`,
+	`8,"back\\",\N,""`,
+	`9,"\N",\N,"\\N"`,
+	`"open`,
+	`trailing\`,
+}
+
+func addSnippets(f *testing.F, records []string) {
+	for _, record := range dumpSnippets {
+		f.Add(record)
+	}
+	for _, record := range records {
+		f.Add(record)
+	}
+}
+
+func FuzzLineSplit(f *testing.F) {
+	addSnippets(f, fixtureRecords(f)[:50])
+
+	splitters := make(map[string]*Splitter)
+	for name, d := range Dialects {
+		splitters[name] = NewSplitter(d)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		fields, err := LineSplit(line)
+
+		for name, d := range Dialects {
+			want, wantErr := d.SplitFields(line)
+			got, gotErr := splitters[name].SplitFields(line)
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Fatalf("%s: Dialect.SplitFields error %v, Splitter.SplitFields error %v", name, wantErr, gotErr)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("%s: Dialect.SplitFields %+v, Splitter.SplitFields %+v", name, want, got)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+
+		// what was split writes back to a line that splits the same way
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.Write(fields); err != nil {
+			t.Fatalf("Write(%q): %s", fields, err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		written := strings.TrimSuffix(buf.String(), "\n")
+		got, err := LineSplit(written)
+		if err != nil {
+			t.Fatalf("LineSplit(%q): %s", written, err)
+		}
+		if !reflect.DeepEqual(fields, got) {
+			t.Fatalf("%q wrote %q, which splits into %q", fields, written, got)
+		}
+	})
+}
+
+func FuzzReader(f *testing.F) {
+	f.Add("id,name,parent_id,date\n" + strings.Join(dumpSnippets, "\n"))
+	f.Add("id,name\r\n1,\"two\r\nlines\"\r\n2,\"quote \"\"\r\n\r\ninside\"\"\"\r\n3,\"open")
+	f.Add("1,foo\n2,\"multi\\\nline\\\nvalue\"\n\n3,\"back\\\\\"\r\n4,bar")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for name, d := range Dialects {
+			r := NewReader(strings.NewReader(input))
+			r.Dialect = d
+
+			line := 0
+			for {
+				fields, err := r.ReadFields()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if _, ok := err.(*ParseError); !ok {
+						t.Fatalf("%s: unexpected error %v", name, err)
+					}
+					continue
+				}
+
+				if r.Line() <= line {
+					t.Fatalf("%s: record on line %d after one on line %d", name, r.Line(), line)
+				}
+				line = r.Line()
+
+				for i := range fields {
+					r.FieldPos(i)
+				}
+			}
+		}
+	})
+}
+
+func FuzzWriterRoundTrip(f *testing.F) {
+	for _, record := range dumpSnippets {
+		fields, err := LineSplit(record)
+		if err != nil || len(fields) < 3 {
+			continue
+		}
+		f.Add(fields[0], fields[1], fields[2], false)
+	}
+	f.Add("", "", "", true)
+
+	f.Fuzz(func(t *testing.T, a, b, c string, null bool) {
+		fields := []Field{{Value: a}, {Value: b, Null: null}, {Value: c}}
+		if null {
+			fields[1].Value = ""
+		}
+
+		for name, d := range Dialects {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.Dialect = d
+			if err := w.WriteFields(fields); err != nil {
+				// not every text can be written without escapes
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r := NewReader(&buf)
+			r.Dialect = d
+			got, err := r.ReadFields()
+			if err != nil {
+				t.Fatalf("%s: ReadFields(%q): %s", name, buf.String(), err)
+			}
+
+			want := fields
+			if d.Null == "" && null {
+				// without a marker, NULL is written as an empty field
+				want = []Field{fields[0], {}, fields[2]}
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("%s: wrote %+v, read %+v", name, want, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HeaderError describes how the header of an input differs from the
+// columns it was expected to have.
+type HeaderError struct {
+	Expected []string
+	Got      []string
+	// Missing lists the expected columns the header lacks.
+	Missing []string
+	// Unexpected lists the header columns that were not expected.
+	Unexpected []string
+	// Duplicated lists the columns the header holds more than once.
+	Duplicated []string
+}
+
+func (e *HeaderError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unexpected) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(e.Unexpected, ", "))
+	}
+	if len(e.Duplicated) > 0 {
+		problems = append(problems, "duplicated "+strings.Join(e.Duplicated, ", "))
+	}
+	if len(problems) == 0 {
+		problems = append(problems, "reordered")
+	}
+
+	return fmt.Sprintf("header changed (%s): expected %q, got %q", strings.Join(problems, "; "), strings.Join(e.Expected, ","), strings.Join(e.Got, ","))
+}
+
+func compareHeader(expected, header []string) *HeaderError {
+	e := &HeaderError{Expected: expected, Got: header}
+
+	seen := make(map[string]bool, len(header))
+	for _, name := range header {
+		if seen[name] {
+			e.Duplicated = append(e.Duplicated, name)
+		}
+		seen[name] = true
+
+		if indexOf(expected, name) < 0 {
+			e.Unexpected = append(e.Unexpected, name)
+		}
+	}
+
+	for _, name := range expected {
+		if !seen[name] {
+			e.Missing = append(e.Missing, name)
+		}
+	}
+
+	return e
+}
+
+// CheckHeader returns a *HeaderError unless header holds exactly the
+// expected columns, in the same order.
+func CheckHeader(expected, header []string) error {
+	if len(expected) == len(header) {
+		same := true
+		for i := range expected {
+			if expected[i] != header[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return nil
+		}
+	}
+
+	return compareHeader(expected, header)
+}
+
+// MapColumns returns, for every expected column, the index of the header
+// field holding it, so records can be read by name however the columns
+// are ordered. Columns the header has on top of the expected ones are
+// ignored. It returns a *HeaderError when an expected column is missing or
+// the header names one of them twice.
+func MapColumns(expected, header []string) ([]int, error) {
+	if e := compareHeader(expected, header); len(e.Missing) > 0 || len(e.Duplicated) > 0 {
+		return nil, e
+	}
+
+	index := make([]int, len(expected))
+	for i, name := range expected {
+		index[i] = indexOf(header, name)
+	}
+
+	return index, nil
+}
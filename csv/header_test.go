@@ -0,0 +1,59 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheckHeader(t *testing.T) {
+	expected := []string{"id", "name", "parent_id", "date"}
+
+	assert.NoError(t, CheckHeader(expected, []string{"id", "name", "parent_id", "date"}))
+
+	tests := []struct {
+		header []string
+		want   HeaderError
+		msg    string
+	}{
+		{
+			header: []string{"id", "parent_id", "name", "date"},
+			msg:    "reordered",
+		},
+		{
+			header: []string{"id", "name", "date"},
+			want:   HeaderError{Missing: []string{"parent_id"}},
+			msg:    "missing parent_id",
+		},
+		{
+			header: []string{"id", "name", "kind", "parent_id", "date", "name"},
+			want:   HeaderError{Unexpected: []string{"kind"}, Duplicated: []string{"name"}},
+			msg:    "unexpected kind; duplicated name",
+		},
+	}
+
+	for _, test := range tests {
+		err := CheckHeader(expected, test.header)
+
+		var he *HeaderError
+		require.ErrorAs(t, err, &he)
+		assert.Equal(t, test.want.Missing, he.Missing)
+		assert.Equal(t, test.want.Unexpected, he.Unexpected)
+		assert.Equal(t, test.want.Duplicated, he.Duplicated)
+		assert.Contains(t, err.Error(), test.msg)
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	expected := []string{"id", "name", "parent_id", "date"}
+
+	index, err := MapColumns(expected, []string{"date", "kind", "id", "parent_id", "name"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 3, 0}, index)
+
+	_, err = MapColumns(expected, []string{"id", "name", "date"})
+	assert.ErrorContains(t, err, "missing parent_id")
+
+	_, err = MapColumns(expected, []string{"id", "name", "parent_id", "date", "id"})
+	assert.ErrorContains(t, err, "duplicated id")
+}
@@ -1,49 +1,72 @@
 package csv
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
-func LineSplit(line string) ([]string, error) {
-	var fields []string
-
-	var currentField string
-	var inQuotes bool
-	for i := 0; i < len(line); i++ {
-		c := line[i]
-
-		switch c {
-		case '"':
-			inQuotes = !inQuotes
-		case ',':
-			if !inQuotes {
-				fields = append(fields, currentField)
-				currentField = ""
-			}
-		case '\\':
-			if i+1 >= len(line) {
-				return nil, fmt.Errorf("unexpected EOF")
-			}
-			i++
-			c = line[i]
-			switch c {
-			case 'n':
-				currentField += "\n"
-			case 't':
-				currentField += "\t"
-			case ',', '"', '\\':
-				currentField += string(c)
-			default:
-				currentField += "\\" + string(c)
-			}
-		default:
-			currentField += string(c)
-		}
-	}
+var (
+	// ErrTrailingEscape is reported for a backslash with nothing after it.
+	ErrTrailingEscape = errors.New("unexpected EOF")
+	// ErrUnterminatedQuote is reported for a quote that is never closed.
+	ErrUnterminatedQuote = errors.New("unterminated quoted field")
+)
+
+// ParseError tells where a record could not be parsed. Line and Column are
+// 1-based, and Column counts bytes.
+type ParseError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// splitError is an error at a byte offset of the record being split.
+type splitError struct {
+	offset int
+	err    error
+}
+
+func (e *splitError) Error() string {
+	return e.err.Error()
+}
 
-	if inQuotes {
-		return nil, fmt.Errorf("unterminated quoted field")
+// position turns a byte offset of a record starting on firstLine into a
+// line and column. The record holds a newline wherever it spans lines.
+func position(firstLine int, record string, offset int) (line, column int) {
+	line = firstLine + strings.Count(record[:offset], "\n")
+	column = offset - strings.LastIndexByte(record[:offset], '\n')
+
+	return line, column
+}
+
+// parseError positions err, as returned by Dialect.split, in a record starting
+// on firstLine.
+func parseError(firstLine int, record string, err error) error {
+	var se *splitError
+	if !errors.As(err, &se) {
+		return err
 	}
 
-	fields = append(fields, currentField)
+	line, column := position(firstLine, record, se.offset)
+
+	return &ParseError{Line: line, Column: column, Err: se.err}
+}
 
-	return fields, nil
+// LineSplit splits a record of an omdb dump into its unescaped fields.
+// Errors are *ParseError values. It is MySQL.Split.
+//
+// Before the streaming Reader, LineSplit dropped the commas inside quoted
+// fields, so "Sequel, The" came out as "Sequel The". They are now kept.
+// Since the dialects, \r reads as a carriage return rather than as is.
+func LineSplit(line string) ([]string, error) {
+	return MySQL.Split(line)
 }
@@ -6,40 +6,81 @@ import (
 	"testing"
 )
 
+var lineSplitTests = []struct {
+	line string
+	want []string
+}{
+	{
+		line: `1,"foo",2,"bar",\N,"foo\nbar",foo\nbar,foo\tand\tbar`,
+		want: []string{
+			"1",
+			"foo",
+			"2",
+			"bar",
+			"\\N",
+			"foo\nbar",
+			"foo\nbar",
+			"foo\tand\tbar",
+		},
+	},
+	{
+		line: `"TV U600 32\"",Smart TV,32\",,`,
+		want: []string{
+			"TV U600 32\"",
+			"Smart TV",
+			"32\"",
+			"",
+			"",
+		},
+	},
+	{
+		line: `500,"Sequel, The",\N`,
+		want: []string{
+			"500",
+			"Sequel, The",
+			"\\N",
+		},
+	},
+}
+
 func TestLineSplit(t *testing.T) {
+	for _, test := range lineSplitTests {
+		got, err := LineSplit(test.line)
+
+		require.NoError(t, err, "LineSplit(%q)", test.line)
+		assert.Equal(t, test.want, got)
+	}
+}
+
+// TestLineSplitChanges pins down where LineSplit, now MySQL.Split, differs
+// from the original implementation, kept as concatLineSplit.
+func TestLineSplitChanges(t *testing.T) {
 	tests := []struct {
 		line string
+		old  []string
 		want []string
 	}{
-		{
-			line: `1,"foo",2,"bar",\N,"foo\nbar",foo\nbar,foo\tand\tbar`,
-			want: []string{
-				"1",
-				"foo",
-				"2",
-				"bar",
-				"\\N",
-				"foo\nbar",
-				"foo\nbar",
-				"foo\tand\tbar",
-			},
-		},
-		{
-			line: `"TV U600 32\"",Smart TV,32\",,`,
-			want: []string{
-				"TV U600 32\"",
-				"Smart TV",
-				"32\"",
-				"",
-				"",
-			},
-		},
+		// a comma in quotes was dropped, it is now kept
+		{line: `a,"b,c",d`, old: []string{"a", "bc", "d"}, want: []string{"a", "b,c", "d"}},
+		{line: `a"b,c"d,e`, old: []string{"abcd", "e"}, want: []string{"ab,cd", "e"}},
+		// \r kept its backslash, it is now a carriage return like \n is a
+		// newline
+		{line: `x\ry,"a\r\nb"`, old: []string{`x\ry`, "a\\r\nb"}, want: []string{"x\ry", "a\r\nb"}},
 	}
 
 	for _, test := range tests {
-		got, err := LineSplit(test.line)
+		old, err := concatLineSplit(test.line)
+		require.NoError(t, err)
+		assert.Equal(t, test.old, old, "concatLineSplit(%q)", test.line)
 
-		require.NoError(t, err, "LineSplit(%q)", test.line)
-		assert.Equal(t, test.want, got)
+		got, err := LineSplit(test.line)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, got, "LineSplit(%q)", test.line)
 	}
+
+	// errors now tell where they are
+	_, err := LineSplit(`1,"unterminated`)
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, 3, pe.Column)
 }
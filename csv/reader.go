@@ -0,0 +1,177 @@
+package csv
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Reader reads the records of an omdb dump, or of any other Dialect. Unlike
+// a bufio.Scanner it has no limit on the length of a line, and it joins the
+// lines of a record spanning several of them.
+type Reader struct {
+	// Dialect is the flavour of the input, MySQL unless changed.
+	Dialect Dialect
+	// Continuations makes a line ending in an unescaped backslash continue
+	// on the next one, for dialects with backslash escapes. NewReader
+	// enables it.
+	Continuations bool
+
+	r *bufio.Reader
+	// line is the number of lines read so far.
+	line int
+
+	// record, the line it started on and where its fields start, for
+	// FieldPos.
+	record     string
+	recordLine int
+	starts     []int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		Dialect:       MySQL,
+		Continuations: true,
+		r:             bufio.NewReader(r),
+	}
+}
+
+// readLine returns the next line and, separately, its line ending.
+func (r *Reader) readLine() (string, string, error) {
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	r.line++
+
+	var ending string
+	if strings.HasSuffix(line, "\n") {
+		ending = "\n"
+		if strings.HasSuffix(line, "\r\n") {
+			ending = "\r\n"
+		}
+	}
+
+	return line[:len(line)-len(ending)], ending, nil
+}
+
+// continues reports whether line ends in a backslash that is not itself
+// escaped.
+func continues(line string) bool {
+	var n int
+	for n < len(line) && line[len(line)-1-n] == '\\' {
+		n++
+	}
+
+	return n%2 == 1
+}
+
+// ReadRecord returns the next record as it appears in the input, except
+// that the backslash ending each line of a record spanning several is
+// replaced by a newline. In dialects without escapes a record spans lines
+// while a quoted field is open, and keeps its line endings. Empty lines
+// are skipped. It returns io.EOF once the input is exhausted.
+func (r *Reader) ReadRecord() (string, error) {
+	r.starts = nil
+
+	for {
+		line, ending, err := r.readLine()
+		if err != nil {
+			return "", err
+		}
+		r.recordLine = r.line
+
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case r.Dialect.Escape == EscapeBackslash && r.Continuations && continues(line):
+			var record strings.Builder
+			for continues(line) {
+				record.WriteString(line[:len(line)-1])
+				record.WriteByte('\n')
+
+				next, _, err := r.readLine()
+				if err == io.EOF {
+					return "", &ParseError{Line: r.line, Column: len(line), Err: ErrTrailingEscape}
+				}
+				if err != nil {
+					return "", err
+				}
+				line = next
+			}
+			record.WriteString(line)
+			line = record.String()
+		case r.Dialect.Escape == EscapeNone && r.Dialect.quoteOpen(line, false):
+			var record strings.Builder
+			for open := true; open; {
+				record.WriteString(line)
+				record.WriteString(ending)
+
+				// an unterminated quote is reported once the record is split
+				line, ending, err = r.readLine()
+				if err == io.EOF {
+					line = ""
+					break
+				}
+				if err != nil {
+					return "", err
+				}
+				open = r.Dialect.quoteOpen(line, open)
+			}
+			record.WriteString(line)
+			line = record.String()
+		}
+
+		r.record = line
+
+		return line, nil
+	}
+}
+
+// Read returns the unescaped fields of the next record, NULL ones included
+// as the Null marker of the dialect. Records that can not be split are
+// reported as *ParseError values, positioned in the input.
+func (r *Reader) Read() ([]string, error) {
+	fields, err := r.ReadFields()
+	if err != nil {
+		return nil, err
+	}
+
+	return Strings(fields, r.Dialect.Null), nil
+}
+
+// ReadFields is like Read, but tells NULL fields apart.
+func (r *Reader) ReadFields() ([]Field, error) {
+	record, err := r.ReadRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	fields, starts, err := r.Dialect.split(record)
+	if err != nil {
+		return nil, parseError(r.recordLine, record, err)
+	}
+	r.starts = starts
+
+	return fields, nil
+}
+
+// Line returns the line the last record read started on.
+func (r *Reader) Line() int {
+	return r.recordLine
+}
+
+// FieldPos returns the line and column the given field of the record last
+// returned by Read or ReadFields starts at.
+func (r *Reader) FieldPos(field int) (line, column int) {
+	if field < 0 || field >= len(r.starts) {
+		panic("out of range index passed to FieldPos")
+	}
+
+	return position(r.recordLine, r.record, r.starts[field])
+}
@@ -0,0 +1,104 @@
+package csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReaderReadRecord(t *testing.T) {
+	input := "1,foo\n2,\"multi\\\nline\\\nvalue\"\n\n3,\"back\\\\\"\r\n4,bar"
+	r := NewReader(strings.NewReader(input))
+
+	want := []struct {
+		record string
+		line   int
+	}{
+		{record: "1,foo", line: 1},
+		{record: "2,\"multi\nline\nvalue\"", line: 2},
+		{record: `3,"back\\"`, line: 6},
+		{record: "4,bar", line: 7},
+	}
+
+	for _, w := range want {
+		record, err := r.ReadRecord()
+		require.NoError(t, err)
+		assert.Equal(t, w.record, record)
+		assert.Equal(t, w.line, r.Line())
+	}
+
+	_, err := r.ReadRecord()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReaderWithoutContinuations(t *testing.T) {
+	r := NewReader(strings.NewReader("a\\\nb\n"))
+	r.Continuations = false
+
+	for _, want := range []string{`a\`, "b"} {
+		record, err := r.ReadRecord()
+		require.NoError(t, err)
+		assert.Equal(t, want, record)
+	}
+}
+
+func TestReaderLongLine(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	r := NewReader(strings.NewReader("1,\"" + long + "\"\n2,\"short\"\n"))
+
+	fields, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", long}, fields)
+
+	fields, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "short"}, fields)
+}
+
+func TestReaderFieldPos(t *testing.T) {
+	r := NewReader(strings.NewReader("id,name\n7,\"A title\\\nspanning lines\",1977\n"))
+
+	_, err := r.Read()
+	require.NoError(t, err)
+
+	fields, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7", "A title\nspanning lines", "1977"}, fields)
+
+	want := [][2]int{{2, 1}, {2, 3}, {3, 17}}
+	for i, w := range want {
+		line, column := r.FieldPos(i)
+		assert.Equal(t, w, [2]int{line, column}, "field %d", i)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+		err    error
+	}{
+		{input: "1,foo\\", line: 1, column: 6, err: ErrTrailingEscape},
+		{input: "1,foo\n2,\"bar\\\nbaz\\", line: 3, column: 4, err: ErrTrailingEscape},
+		{input: "1,foo\n2,\"a\\\nb,c", line: 2, column: 3, err: ErrUnterminatedQuote},
+	}
+
+	for _, test := range tests {
+		r := NewReader(strings.NewReader(test.input))
+
+		var err error
+		for err == nil {
+			_, err = r.Read()
+		}
+
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe), "%q: %v", test.input, err)
+		assert.Equal(t, test.line, pe.Line, test.input)
+		assert.Equal(t, test.column, pe.Column, test.input)
+		assert.ErrorIs(t, err, test.err, test.input)
+	}
+}
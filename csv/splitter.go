@@ -0,0 +1,173 @@
+package csv
+
+import (
+	"strings"
+)
+
+// Splitter splits records like Dialect.Split, without allocating for the
+// common records. Fields needing no unescaping are returned as substrings
+// of the record, and the slice holding them is reused: it is only valid
+// until the next call to Split or SplitFields. A Splitter must not be used concurrently.
+type Splitter struct {
+	Dialect Dialect
+
+	fields  []Field
+	strings []string
+	buf     []byte
+	// specials are the bytes ending the fast path of an unquoted field.
+	specials string
+	// specialsFor is the dialect specials was built for.
+	specialsFor Dialect
+}
+
+func NewSplitter(d Dialect) *Splitter {
+	return &Splitter{Dialect: d}
+}
+
+func (s *Splitter) init() {
+	if s.specials != "" && s.specialsFor == s.Dialect {
+		return
+	}
+
+	specials := []byte{s.Dialect.Delimiter}
+	if s.Dialect.Quote != 0 {
+		specials = append(specials, s.Dialect.Quote)
+	}
+	if s.Dialect.Escape == EscapeBackslash {
+		specials = append(specials, '\\')
+	}
+	s.specials = string(specials)
+	s.specialsFor = s.Dialect
+}
+
+// Split splits a record into its unescaped fields, NULL ones included as
+// the Null marker. Errors are *ParseError values.
+func (s *Splitter) Split(line string) ([]string, error) {
+	fields, err := s.SplitFields(line)
+	if err != nil {
+		return nil, err
+	}
+	s.strings = appendStrings(s.strings[:0], fields, s.Dialect.Null)
+
+	return s.strings, nil
+}
+
+// SplitFields splits a record into its unescaped fields, telling NULL ones
+// apart. Errors are *ParseError values.
+func (s *Splitter) SplitFields(line string) ([]Field, error) {
+	s.init()
+	s.fields = s.fields[:0]
+
+	for start := 0; ; {
+		field, next, err := s.field(line, start)
+		if err != nil {
+			return nil, parseError(1, line, err)
+		}
+		s.fields = append(s.fields, field)
+
+		if next > len(line) {
+			return s.fields, nil
+		}
+		start = next
+	}
+}
+
+// field returns the field starting at offset start, and the offset of the
+// next one, which is past the end of line for the last field.
+func (s *Splitter) field(line string, start int) (Field, int, error) {
+	d := s.Dialect
+	rest := line[start:]
+
+	if d.Null != "" && strings.HasPrefix(rest, d.Null) {
+		switch n := len(d.Null); {
+		case n == len(rest):
+			return Field{Null: true}, len(line) + 1, nil
+		case rest[n] == d.Delimiter:
+			return Field{Null: true}, start + n + 1, nil
+		}
+	}
+
+	if d.Quote != 0 && len(rest) > 0 && rest[0] == d.Quote {
+		// a quoted field without escapes, followed by a delimiter or the
+		// end of the record
+		end := strings.IndexByte(rest[1:], d.Quote) + 1
+		if end > 0 && (d.Escape != EscapeBackslash || strings.IndexByte(rest[1:end], '\\') < 0) {
+			switch {
+			case end+1 == len(rest):
+				return Field{Value: rest[1:end]}, len(line) + 1, nil
+			case rest[end+1] == d.Delimiter:
+				return Field{Value: rest[1:end]}, start + end + 2, nil
+			}
+		}
+
+		return s.slowField(line, start)
+	}
+
+	end := strings.IndexAny(rest, s.specials)
+	switch {
+	case end < 0:
+		return Field{Value: rest}, len(line) + 1, nil
+	case rest[end] == d.Delimiter:
+		return Field{Value: rest[:end]}, start + end + 1, nil
+	}
+
+	return s.slowField(line, start)
+}
+
+// slowField unescapes the field starting at offset start into the buffer of
+// the splitter, the same way Dialect.split does.
+func (s *Splitter) slowField(line string, start int) (Field, int, error) {
+	d := s.Dialect
+	s.buf = s.buf[:0]
+
+	var inQuotes bool
+	var quoteStart int
+	for i := start; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case d.Quote != 0 && c == d.Quote:
+			if inQuotes && d.DoubleQuote && i+1 < len(line) && line[i+1] == d.Quote {
+				s.buf = append(s.buf, c)
+				i++
+				continue
+			}
+			if !inQuotes {
+				quoteStart = i
+			}
+			inQuotes = !inQuotes
+		case c == d.Delimiter:
+			if inQuotes {
+				s.buf = append(s.buf, c)
+				continue
+			}
+			return Field{Value: string(s.buf)}, i + 1, nil
+		case c == '\\' && d.Escape == EscapeBackslash:
+			if i+1 >= len(line) {
+				return Field{}, 0, &splitError{offset: i, err: ErrTrailingEscape}
+			}
+			i++
+			c = line[i]
+			switch {
+			case c == 'n':
+				s.buf = append(s.buf, '\n')
+			case c == 'r':
+				s.buf = append(s.buf, '\r')
+			case c == 't':
+				s.buf = append(s.buf, '\t')
+			case c == d.Delimiter, c == '\\', d.Quote != 0 && c == d.Quote:
+				s.buf = append(s.buf, c)
+			default:
+				s.buf = append(s.buf, '\\', c)
+			}
+		default:
+			s.buf = append(s.buf, c)
+		}
+	}
+
+	if inQuotes {
+		return Field{}, 0, &splitError{offset: quoteStart, err: ErrUnterminatedQuote}
+	}
+
+	return Field{Value: string(s.buf)}, len(line) + 1, nil
+}
@@ -0,0 +1,219 @@
+package csv
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestSplitter(t *testing.T) {
+	s := NewSplitter(MySQL)
+	for _, test := range lineSplitTests {
+		got, err := s.Split(test.line)
+
+		require.NoError(t, err, "Split(%q)", test.line)
+		assert.Equal(t, test.want, got)
+	}
+}
+
+// line is a random record, made of the bytes that matter to the dialects.
+type line string
+
+func (line) Generate(r *rand.Rand, size int) reflect.Value {
+	alphabet := []string{`\`, `"`, ",", "\t", "\n", "N", "n", "a", " ", `""`, `\N`, `","`}
+
+	var b strings.Builder
+	for n := r.Intn(size + 1); n > 0; n-- {
+		b.WriteString(alphabet[r.Intn(len(alphabet))])
+	}
+
+	return reflect.ValueOf(line(b.String()))
+}
+
+func TestSplitterMatchesDialect(t *testing.T) {
+	for name, dialect := range Dialects {
+		s := NewSplitter(dialect)
+
+		same := func(l line) bool {
+			want, wantErr := dialect.Split(string(l))
+			got, gotErr := s.Split(string(l))
+			if (wantErr == nil) != (gotErr == nil) || (wantErr != nil && wantErr.Error() != gotErr.Error()) {
+				t.Logf("%s: %q: want error %v, got %v", name, l, wantErr, gotErr)
+				return false
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Logf("%s: %q: want %q, got %q", name, l, want, got)
+				return false
+			}
+
+			wantFields, _ := dialect.SplitFields(string(l))
+			gotFields, _ := s.SplitFields(string(l))
+			if !reflect.DeepEqual(wantFields, gotFields) {
+				t.Logf("%s: %q: want %+v, got %+v", name, l, wantFields, gotFields)
+				return false
+			}
+			return true
+		}
+
+		require.NoError(t, quick.Check(same, &quick.Config{MaxCount: 5000}), name)
+	}
+}
+
+func TestSplitterAllocs(t *testing.T) {
+	s := NewSplitter(MySQL)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := s.Split(`123,"Movie 123",11,"2001-01-01"`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+// fixtureRecords returns the records of testdata/all_movies_excerpt.csv.bz2,
+// a hand-assembled excerpt in the format of the all_movies dump: film titles
+// with commas, quotes, backslashes and non-ASCII letters, collections with
+// their parts, a record spanning two lines, and NULL dates.
+func fixtureRecords(tb testing.TB) []string {
+	tb.Helper()
+
+	f, err := os.Open("testdata/all_movies_excerpt.csv.bz2")
+	require.NoError(tb, err)
+	defer f.Close()
+
+	r := NewReader(bzip2.NewReader(f))
+	var records []string
+	for {
+		record, err := r.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(tb, err)
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func benchmarkRecords(b *testing.B) []string {
+	records := fixtureRecords(b)
+
+	var size int
+	for _, record := range records {
+		size += len(record)
+	}
+	b.SetBytes(int64(size))
+
+	return records
+}
+
+// concatLineSplit is LineSplit as it was before Splitter, growing every
+// field with +=. It is the baseline of the benchmarks.
+func concatLineSplit(line string) ([]string, error) {
+	var fields []string
+
+	var currentField string
+	var inQuotes bool
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch c {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				fields = append(fields, currentField)
+				currentField = ""
+			}
+		case '\\':
+			if i+1 >= len(line) {
+				return nil, fmt.Errorf("unexpected EOF")
+			}
+			i++
+			c = line[i]
+			switch c {
+			case 'n':
+				currentField += "\n"
+			case 't':
+				currentField += "\t"
+			case ',', '"', '\\':
+				currentField += string(c)
+			default:
+				currentField += "\\" + string(c)
+			}
+		default:
+			currentField += string(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quoted field")
+	}
+
+	fields = append(fields, currentField)
+
+	return fields, nil
+}
+
+func TestFixtureRecords(t *testing.T) {
+	records := fixtureRecords(t)
+	// 152 lines, the header included, with one record spanning two
+	require.Equal(t, 151, len(records))
+
+	s := NewSplitter(MySQL)
+	for _, record := range records {
+		fields, err := s.SplitFields(record)
+		require.NoError(t, err, record)
+		require.Len(t, fields, 4, record)
+	}
+}
+
+func BenchmarkConcatLineSplit(b *testing.B) {
+	records := benchmarkRecords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, record := range records {
+			if _, err := concatLineSplit(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkLineSplit(b *testing.B) {
+	records := benchmarkRecords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, record := range records {
+			if _, err := LineSplit(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSplitter(b *testing.B) {
+	records := benchmarkRecords(b)
+	s := NewSplitter(MySQL)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, record := range records {
+			if _, err := s.Split(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer writes records in the dialect of the omdb dumps, or any other
+// Dialect, one per line. Writes are buffered, so Flush must be called once
+// done.
+type Writer struct {
+	// Dialect is the flavour of the output, MySQL unless changed.
+	Dialect Dialect
+
+	w *bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{Dialect: MySQL, w: bufio.NewWriter(w)}
+}
+
+// writeEscaped writes field with backslash escapes. Newlines are written as
+// \n so every record fits on a single line, and the delimiter is escaped
+// even inside quotes, like omdb does.
+func (w *Writer) writeEscaped(field string) {
+	d := w.Dialect
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == '\n':
+			w.w.WriteString(`\n`)
+		case c == '\r':
+			w.w.WriteString(`\r`)
+		case c == '\t':
+			w.w.WriteString(`\t`)
+		case c == '\\', c == d.Delimiter, d.Quote != 0 && c == d.Quote:
+			w.w.WriteByte('\\')
+			w.w.WriteByte(c)
+		default:
+			w.w.WriteByte(c)
+		}
+	}
+}
+
+// writeField writes a text field. With backslash escapes, text is always
+// quoted when the dialect quotes, which also keeps a trailing backslash
+// from being mistaken for a line continuation. Otherwise it is only quoted
+// when needed.
+func (w *Writer) writeField(field string) error {
+	d := w.Dialect
+
+	if d.Escape == EscapeBackslash {
+		if d.Quote != 0 {
+			w.w.WriteByte(d.Quote)
+		}
+		w.writeEscaped(field)
+		if d.Quote != 0 {
+			w.w.WriteByte(d.Quote)
+		}
+		return nil
+	}
+
+	if !d.needsQuotes(field) {
+		w.w.WriteString(field)
+		return nil
+	}
+	if d.Quote == 0 || (!d.DoubleQuote && strings.IndexByte(field, d.Quote) >= 0) {
+		return fmt.Errorf("field %q can not be written in this dialect", field)
+	}
+
+	w.w.WriteByte(d.Quote)
+	quote := string(d.Quote)
+	w.w.WriteString(strings.ReplaceAll(field, quote, quote+quote))
+	w.w.WriteByte(d.Quote)
+
+	return nil
+}
+
+// WriteHeader writes the column names, never quoted with backslash
+// escapes.
+func (w *Writer) WriteHeader(columns []string) error {
+	for i, column := range columns {
+		if i > 0 {
+			w.w.WriteByte(w.Dialect.Delimiter)
+		}
+
+		if w.Dialect.Escape == EscapeBackslash {
+			w.writeEscaped(column)
+			continue
+		}
+		if err := w.writeField(column); err != nil {
+			return err
+		}
+	}
+
+	return w.w.WriteByte('\n')
+}
+
+// Write writes a record of text fields. A field holding the NULL marker of
+// the dialect is written as is.
+func (w *Writer) Write(record []string) error {
+	// an empty line would be skipped when reading
+	if len(record) == 1 && record[0] == "" && w.Dialect.Quote != 0 {
+		w.w.WriteByte(w.Dialect.Quote)
+		w.w.WriteByte(w.Dialect.Quote)
+		return w.w.WriteByte('\n')
+	}
+
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteByte(w.Dialect.Delimiter)
+		}
+
+		if w.Dialect.Null != "" && field == w.Dialect.Null {
+			w.w.WriteString(field)
+			continue
+		}
+		if err := w.writeField(field); err != nil {
+			return err
+		}
+	}
+
+	return w.w.WriteByte('\n')
+}
+
+// WriteFields writes a record, with NULL fields as the NULL marker of the
+// dialect. A field holding the marker as text is escaped or quoted instead,
+// when the dialect allows.
+func (w *Writer) WriteFields(fields []Field) error {
+	if len(fields) == 1 && !fields[0].Null && fields[0].Value == "" && w.Dialect.Quote != 0 {
+		return w.Write([]string{""})
+	}
+
+	for i, field := range fields {
+		if i > 0 {
+			w.w.WriteByte(w.Dialect.Delimiter)
+		}
+
+		if field.Null {
+			w.w.WriteString(w.Dialect.Null)
+			continue
+		}
+		if err := w.writeField(field.Value); err != nil {
+			return err
+		}
+	}
+
+	return w.w.WriteByte('\n')
+}
+
+// WriteValues writes a record of typed values: nil is written as the NULL
+// marker of the dialect, numbers and booleans unquoted and strings as text
+// fields. A value of any other
+// type fails the write, possibly leaving part of the record written.
+func (w *Writer) WriteValues(values []any) error {
+	for i, v := range values {
+		if i > 0 {
+			w.w.WriteByte(w.Dialect.Delimiter)
+		}
+
+		switch v := v.(type) {
+		case nil:
+			w.w.WriteString(w.Dialect.Null)
+		case string:
+			if err := w.writeField(v); err != nil {
+				return err
+			}
+		case int:
+			w.w.WriteString(strconv.Itoa(v))
+		case int32:
+			w.w.WriteString(strconv.FormatInt(int64(v), 10))
+		case int64:
+			w.w.WriteString(strconv.FormatInt(v, 10))
+		case float64:
+			w.w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		case bool:
+			w.w.WriteString(strconv.FormatBool(v))
+		default:
+			return fmt.Errorf("unsupported value type %T", v)
+		}
+	}
+
+	return w.w.WriteByte('\n')
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,158 @@
+package csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// record is a random record, biased towards the characters the dialect
+// has to escape.
+type record []string
+
+func (record) Generate(r *rand.Rand, size int) reflect.Value {
+	alphabet := []string{`\`, `"`, ",", "\n", "\t", "\r", "N", "n", "t", "a", " ", "é", "\x00", Null}
+
+	fields := make(record, 1+r.Intn(6))
+	for i := range fields {
+		var b strings.Builder
+		for n := r.Intn(size + 1); n > 0; n-- {
+			b.WriteString(alphabet[r.Intn(len(alphabet))])
+		}
+		fields[i] = b.String()
+	}
+
+	return reflect.ValueOf(fields)
+}
+
+func TestWriterLineSplitRoundTrip(t *testing.T) {
+	roundTrip := func(fields record) bool {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.Write(fields); err != nil {
+			t.Log(err)
+			return false
+		}
+		if err := w.Flush(); err != nil {
+			t.Log(err)
+			return false
+		}
+
+		line := strings.TrimSuffix(buf.String(), "\n")
+		if strings.Contains(line, "\n") {
+			t.Logf("%q spans several lines", line)
+			return false
+		}
+
+		got, err := LineSplit(line)
+		if err != nil {
+			t.Logf("LineSplit(%q): %s", line, err)
+			return false
+		}
+
+		return reflect.DeepEqual([]string(fields), got)
+	}
+
+	require.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 5000}))
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	for name, dialect := range Dialects {
+		t.Run(name, func(t *testing.T) {
+			testWriterReaderRoundTrip(t, dialect)
+		})
+	}
+}
+
+func testWriterReaderRoundTrip(t *testing.T, dialect Dialect) {
+	roundTrip := func(records []record) bool {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Dialect = dialect
+		for _, fields := range records {
+			// a lone empty field can only be written with quotes
+			if dialect.Quote == 0 && len(fields) == 1 && fields[0] == "" {
+				fields[0] = "x"
+			}
+			if err := w.Write(fields); err != nil {
+				t.Log(err)
+				return false
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Log(err)
+			return false
+		}
+
+		r := NewReader(&buf)
+		r.Dialect = dialect
+		for _, want := range records {
+			got, err := r.Read()
+			if err != nil || !reflect.DeepEqual([]string(want), got) {
+				t.Logf("want %q, got %q (%v)", want, got, err)
+				return false
+			}
+		}
+
+		_, err := r.Read()
+		return err == io.EOF
+	}
+
+	require.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 500}))
+}
+
+func TestWriterWriteValues(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteHeader([]string{"id", "name", "parent_id", "date"}))
+	require.NoError(t, w.WriteValues([]any{int64(500), "Sequel, The", 11, nil}))
+	require.NoError(t, w.WriteValues([]any{7, "A \"title\"\nspanning lines", nil, "1977-05-25"}))
+	assert.Error(t, w.WriteValues([]any{struct{}{}}))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "id,name,parent_id,date", lines[0])
+	assert.Equal(t, `500,"Sequel\, The",11,\N`, lines[1])
+	assert.Equal(t, `7,"A \"title\"\nspanning lines",\N,"1977-05-25"`, lines[2])
+
+	fields, err := LineSplit(lines[2])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7", "A \"title\"\nspanning lines", `\N`, "1977-05-25"}, fields)
+}
+
+func TestWriterWriteFields(t *testing.T) {
+	fields := []Field{{Value: "1"}, {Null: true}, {Value: Null}, {}}
+
+	for name, dialect := range Dialects {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Dialect = dialect
+		require.NoError(t, w.WriteFields(fields), name)
+		require.NoError(t, w.Flush(), name)
+
+		r := NewReader(&buf)
+		r.Dialect = dialect
+
+		want := fields
+		if dialect.Null == "" {
+			// NULL can not be told apart from an empty field
+			want = []Field{{Value: "1"}, {}, {Value: Null}, {}}
+		}
+		got, err := r.ReadFields()
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	// a lone empty field is quoted, not to be taken for an empty line
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteFields([]Field{{}}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "\"\"\n", buf.String())
+}
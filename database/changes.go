@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change is a row an import inserted, updated or deleted, as recorded in
+// catalog_changes. Before is nil for inserts and After is nil for deletes.
+type Change struct {
+	ID int64 `json:"id" db:"id"`
+	// TxID is the transaction of the import that made the change.
+	TxID      int64           `json:"txid" db:"txid"`
+	ImportRun string          `json:"import_run" db:"import_run"`
+	Dataset   string          `json:"dataset" db:"dataset"`
+	Op        string          `json:"op" db:"op"`
+	Before    json.RawMessage `json:"before" db:"before"`
+	After     json.RawMessage `json:"after" db:"after"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Cursor returns the position of the change in the feed.
+func (c Change) Cursor() ChangeCursor {
+	return ChangeCursor{TxID: c.TxID, ID: c.ID}
+}
+
+// ChangeCursor is a position in the feed of changes, which is ordered by
+// transaction and then by id. The zero value is the start of the feed.
+type ChangeCursor struct {
+	TxID int64
+	ID   int64
+}
+
+// String formats the cursor as "<txid>-<id>", which ParseChangeCursor
+// reads back.
+func (c ChangeCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.TxID, c.ID)
+}
+
+// ParseChangeCursor parses a cursor formatted by ChangeCursor.String. An
+// empty string, or 0, is the start of the feed.
+func ParseChangeCursor(s string) (ChangeCursor, error) {
+	if s == "" || s == "0" {
+		return ChangeCursor{}, nil
+	}
+
+	txid, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor: %q", s)
+	}
+
+	var c ChangeCursor
+	var err error
+	if c.TxID, err = strconv.ParseInt(txid, 10, 64); err != nil {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor: %q", s)
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor: %q", s)
+	}
+
+	return c, nil
+}
+
+// GetChanges returns up to limit changes recorded after since, in commit
+// order. Pass the cursor of the last change returned to get the next page.
+//
+// Imports run concurrently and commit in any order, so only changes from
+// transactions older than every running one are returned: nothing can be
+// committed before them anymore. A long import thus holds back the feed
+// until it ends.
+func GetChanges(ctx context.Context, db *sql.DB, since ChangeCursor, limit int) ([]Change, error) {
+	const query = `SELECT id, txid, import_run, dataset, op, before, after, created_at
+		FROM catalog_changes
+		WHERE (txid, id) > ($1, $2) AND txid < txid_snapshot_xmin(txid_current_snapshot())
+		ORDER BY txid, id LIMIT $3`
+
+	rows, err := db.QueryContext(ctx, query, since.TxID, since.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []Change{}
+	for rows.Next() {
+		var c Change
+		var before, after []byte
+		if err := rows.Scan(&c.ID, &c.TxID, &c.ImportRun, &c.Dataset, &c.Op, &before, &after, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		c.Before, c.After = before, after
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseChangeCursor(t *testing.T) {
+	for _, s := range []string{"", "0"} {
+		c, err := ParseChangeCursor(s)
+		require.NoError(t, err)
+		assert.Equal(t, ChangeCursor{}, c)
+	}
+
+	c, err := ParseChangeCursor(ChangeCursor{TxID: 1234, ID: 56}.String())
+	require.NoError(t, err)
+	assert.Equal(t, ChangeCursor{TxID: 1234, ID: 56}, c)
+
+	for _, s := range []string{"12", "a-1", "1-b", "1-2-3"} {
+		_, err := ParseChangeCursor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestGetChangesCommitOrder(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	begin := func(run string, id int64) *sql.Tx {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "SELECT set_config('omdb.import_run', $1, true)", run)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "INSERT INTO movies (id, name) VALUES ($1, 'Movie')", id)
+		require.NoError(t, err)
+		return tx
+	}
+
+	// the first import writes first, but commits last
+	first := begin("first", 1)
+	second := begin("second", 2)
+	require.NoError(t, second.Commit())
+
+	changes, err := GetChanges(ctx, db, ChangeCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "changes are held back while an older import runs")
+
+	require.NoError(t, first.Commit())
+
+	changes, err = GetChanges(ctx, db, ChangeCursor{}, 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "first", changes[0].ImportRun)
+
+	changes, err = GetChanges(ctx, db, changes[0].Cursor(), 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "second", changes[0].ImportRun)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -23,14 +24,52 @@ func DB() (*sql.DB, error) {
 }
 
 type Movie struct {
-	ID       int64   `json:"id" db:"id"`
-	Name     string  `json:"name" db:"name"`
-	ParentID *int64  `json:"parent_id" db:"parent_id"`
-	Date     *string `json:"date" db:"date"`
+	ID        int64      `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	ParentID  *int64     `json:"parent_id" db:"parent_id"`
+	Date      *string    `json:"date" db:"date"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
-func GetMovies(db *sql.DB) ([]Movie, error) {
-	rows, err := db.Query("SELECT id, name, parent_id, date FROM movies")
+type MovieLink struct {
+	Source   string  `json:"source" db:"source"`
+	Key      string  `json:"key" db:"key"`
+	MovieID  *int64  `json:"movie_id" db:"movie_id"`
+	Language *string `json:"language_iso_639_1" db:"language_iso_639_1"`
+}
+
+const movieColumns = "id, name, parent_id, date, deleted_at"
+
+type queryOptions struct {
+	includeDeleted bool
+}
+
+// QueryOption changes how movie lookups filter their results.
+type QueryOption func(*queryOptions)
+
+// IncludeDeleted makes lookups return movies that were soft-deleted
+// because they disappeared from the upstream dump.
+func IncludeDeleted() QueryOption {
+	return func(o *queryOptions) {
+		o.includeDeleted = true
+	}
+}
+
+func movieFilter(opts []QueryOption) string {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.includeDeleted {
+		return "TRUE"
+	}
+
+	return "deleted_at IS NULL"
+}
+
+func GetMovies(db *sql.DB, opts ...QueryOption) ([]Movie, error) {
+	rows, err := db.Query("SELECT " + movieColumns + " FROM movies WHERE " + movieFilter(opts))
 	if err != nil {
 		return nil, fmt.Errorf("db.Query: %w", err)
 	}
@@ -39,7 +78,7 @@ func GetMovies(db *sql.DB) ([]Movie, error) {
 	var movies []Movie
 	for rows.Next() {
 		var movie Movie
-		if err := rows.Scan(&movie.ID, &movie.Name, &movie.ParentID, &movie.Date); err != nil {
+		if err := rows.Scan(&movie.ID, &movie.Name, &movie.ParentID, &movie.Date, &movie.DeletedAt); err != nil {
 			return nil, fmt.Errorf("rows.Scan: %w", err)
 		}
 		movies = append(movies, movie)
@@ -48,24 +87,24 @@ func GetMovies(db *sql.DB) ([]Movie, error) {
 	return movies, nil
 }
 
-func GetMovieWithID(db *sql.DB, id int64) (*Movie, error) {
-	row := db.QueryRow("SELECT id, name, parent_id, date FROM movies WHERE id = $1", id)
+func GetMovieWithID(db *sql.DB, id int64, opts ...QueryOption) (*Movie, error) {
+	row := db.QueryRow("SELECT "+movieColumns+" FROM movies WHERE id = $1 AND "+movieFilter(opts), id)
 
 	var movie Movie
-	if err := row.Scan(&movie.ID, &movie.Name, &movie.ParentID, &movie.Date); err != nil {
+	if err := row.Scan(&movie.ID, &movie.Name, &movie.ParentID, &movie.Date, &movie.DeletedAt); err != nil {
 		return nil, fmt.Errorf("row.Scan: %w", err)
 	}
 
 	return &movie, nil
 }
 
-func GetMovieForIMDBID(db *sql.DB, imdbID string) (*Movie, error) {
-	row := db.QueryRow("SELECT movie_id FROM movie_links WHERE source = 'imdbmovie' AND key = $1", imdbID)
+func GetMovieForIMDBID(db *sql.DB, imdbID string, opts ...QueryOption) (*Movie, error) {
+	row := db.QueryRow("SELECT movie_id FROM movie_links WHERE source = 'imdbmovie' AND key = $1 AND movie_id IS NOT NULL", imdbID)
 
 	var movieID int64
 	if err := row.Scan(&movieID); err != nil {
 		return nil, fmt.Errorf("row.Scan: %w", err)
 	}
 
-	return GetMovieWithID(db, movieID)
+	return GetMovieWithID(db, movieID, opts...)
 }
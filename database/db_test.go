@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// testDB connects to the scratch database named by TEST_DATABASE_URL, which
+// is wiped, and skips the test when there is none.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connURL := os.Getenv("TEST_DATABASE_URL")
+	if connURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", connURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS movies (
+			id BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			parent_id BIGINT,
+			date TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS movie_links (
+			source TEXT NOT NULL,
+			key TEXT NOT NULL,
+			movie_id BIGINT NOT NULL,
+			language_iso_639_1 TEXT
+		)`,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, Migrate(ctx, db))
+
+	_, err = db.ExecContext(ctx, "TRUNCATE movies, movie_links, catalog_changes")
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestMovieFilter(t *testing.T) {
+	assert.Equal(t, "deleted_at IS NULL", movieFilter(nil))
+	assert.Equal(t, "TRUE", movieFilter([]QueryOption{IncludeDeleted()}))
+}
+
+func TestLookupsSkipDeleted(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		"INSERT INTO movies (id, name) VALUES (1, 'kept'), (2, 'deleted')",
+		"UPDATE movies SET deleted_at = now() WHERE id = 2",
+		"INSERT INTO movie_links (source, key, movie_id) VALUES ('imdbmovie', 'tt1', 1), ('imdbmovie', 'tt2', 2)",
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+
+	movies, err := GetMovies(db)
+	require.NoError(t, err)
+	require.Len(t, movies, 1)
+	assert.Equal(t, int64(1), movies[0].ID)
+
+	movies, err = GetMovies(db, IncludeDeleted())
+	require.NoError(t, err)
+	assert.Len(t, movies, 2)
+
+	_, err = GetMovieWithID(db, 2)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	movie, err := GetMovieWithID(db, 2, IncludeDeleted())
+	require.NoError(t, err)
+	assert.NotNil(t, movie.DeletedAt)
+
+	_, err = GetMovieForIMDBID(db, "tt2")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	movie, err = GetMovieForIMDBID(db, "tt1")
+	require.NoError(t, err)
+	assert.Equal(t, "kept", movie.Name)
+}
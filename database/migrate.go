@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order by Migrate, each of them once. They are
+// recorded in schema_migrations by their position, so new ones go at the
+// end and applied ones are never edited. They must still be idempotent:
+// databases set up before schema_migrations existed run them all once more.
+var migrations = []string{
+	`ALTER TABLE movies ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+	`CREATE TABLE IF NOT EXISTS import_rejects (
+		id BIGSERIAL PRIMARY KEY,
+		dataset TEXT NOT NULL,
+		line_number INTEGER NOT NULL,
+		raw_line TEXT NOT NULL,
+		error TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS import_jobs (
+		id BIGSERIAL PRIMARY KEY,
+		dataset TEXT NOT NULL,
+		params JSONB NOT NULL DEFAULT '{}',
+		state TEXT NOT NULL DEFAULT 'queued',
+		rows BIGINT NOT NULL DEFAULT 0,
+		rejected BIGINT NOT NULL DEFAULT 0,
+		bytes_read BIGINT NOT NULL DEFAULT 0,
+		total_bytes BIGINT NOT NULL DEFAULT -1,
+		error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		started_at TIMESTAMPTZ,
+		finished_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS import_jobs_state_idx ON import_jobs (state, id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS import_jobs_active_idx ON import_jobs (dataset) WHERE state IN ('queued', 'running')`,
+	`CREATE TABLE IF NOT EXISTS catalog_changes (
+		id BIGSERIAL PRIMARY KEY,
+		import_run TEXT NOT NULL,
+		dataset TEXT NOT NULL,
+		op TEXT NOT NULL,
+		before JSONB,
+		after JSONB,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS catalog_changes_import_run_idx ON catalog_changes (import_run)`,
+	// ids are handed out as rows are written, not as the imports commit, so
+	// the feed pages on the transaction that recorded a change instead. Rows
+	// from before this column existed are all committed and sort first.
+	`ALTER TABLE catalog_changes ADD COLUMN IF NOT EXISTS txid BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE catalog_changes ALTER COLUMN txid SET DEFAULT txid_current()`,
+	`CREATE INDEX IF NOT EXISTS catalog_changes_txid_idx ON catalog_changes (txid, id)`,
+	// record_catalog_change logs the rows touched by an import, which sets
+	// omdb.import_run for the duration of its transaction. Writes made
+	// outside an import are not recorded. Soft deleting or restoring a
+	// movie is reported as a delete or an insert.
+	`CREATE OR REPLACE FUNCTION record_catalog_change() RETURNS trigger AS $$
+	DECLARE
+		run TEXT := current_setting('omdb.import_run', true);
+		op TEXT := lower(TG_OP);
+		old_row JSONB;
+		new_row JSONB;
+	BEGIN
+		IF run IS NULL OR run = '' THEN
+			RETURN NULL;
+		END IF;
+
+		IF TG_OP <> 'INSERT' THEN
+			old_row := to_jsonb(OLD);
+		END IF;
+		IF TG_OP <> 'DELETE' THEN
+			new_row := to_jsonb(NEW);
+		END IF;
+
+		IF TG_OP = 'UPDATE' THEN
+			IF old_row = new_row THEN
+				RETURN NULL;
+			END IF;
+
+			IF old_row->>'deleted_at' IS NULL AND new_row->>'deleted_at' IS NOT NULL THEN
+				op := 'delete';
+			ELSIF old_row->>'deleted_at' IS NOT NULL AND new_row->>'deleted_at' IS NULL THEN
+				op := 'insert';
+			END IF;
+		END IF;
+
+		INSERT INTO catalog_changes (import_run, dataset, op, before, after)
+		VALUES (run, TG_ARGV[0], op, old_row, new_row);
+
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'movies_catalog_changes') THEN
+			CREATE TRIGGER movies_catalog_changes AFTER INSERT OR UPDATE OR DELETE ON movies
+				FOR EACH ROW EXECUTE FUNCTION record_catalog_change('all_movies');
+		END IF;
+	END $$`,
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'movie_links_catalog_changes') THEN
+			CREATE TRIGGER movie_links_catalog_changes AFTER INSERT OR UPDATE OR DELETE ON movie_links
+				FOR EACH ROW EXECUTE FUNCTION record_catalog_change('movie_links');
+		END IF;
+	END $$`,
+	// older imports stored NULL as the \N marker of the dump, or as an
+	// empty date, and a NULL movie id as 0
+	`UPDATE movies SET date = NULL WHERE date IN ('\N', '')`,
+	`ALTER TABLE movie_links ALTER COLUMN movie_id DROP NOT NULL`,
+	`ALTER TABLE movie_links ALTER COLUMN language_iso_639_1 DROP NOT NULL`,
+	`UPDATE movie_links SET language_iso_639_1 = NULL WHERE language_iso_639_1 = '\N'`,
+	`UPDATE movie_links SET movie_id = NULL WHERE movie_id = 0`,
+}
+
+// migrateLockKey is the key of the advisory lock serializing Migrate.
+const migrateLockKey = 0x6f6d64622d6d6967 // "omdb-mig"
+
+// Migrate brings the schema up to date with what the importers and the
+// lookups in this package expect, applying the migrations it has not
+// applied yet. The base movies and movie_links tables must already exist.
+//
+// Some migrations lock whole tables, so Migrate runs once per deploy, with
+// omdbctl migrate, rather than on every request. Concurrent calls wait for
+// each other.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	// advisory locks belong to a session, so keep to a single connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Conn: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrateLockKey); err != nil {
+		return fmt.Errorf("pg_advisory_lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var applied int
+	if err := conn.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations").Scan(&applied); err != nil {
+		return fmt.Errorf("schema_migrations: %w", err)
+	}
+
+	for i := applied; i < len(migrations); i++ {
+		if err := applyMigration(ctx, conn, i+1, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a migration and records it in one transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, version int, stmt string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("conn.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// forgetMigrationsFrom removes the migration starting with prefix, and every
+// later one, from schema_migrations, so the next Migrate applies them again.
+func forgetMigrationsFrom(t *testing.T, db *sql.DB, prefix string) {
+	t.Helper()
+
+	for i, stmt := range migrations {
+		if strings.HasPrefix(stmt, prefix) {
+			_, err := db.Exec("DELETE FROM schema_migrations WHERE version > $1", i)
+			require.NoError(t, err)
+			return
+		}
+	}
+
+	t.Fatalf("no migration starts with %q", prefix)
+}
+
+func TestMigrateOnce(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	// concurrent calls wait for each other instead of racing
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- Migrate(ctx, db) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+
+	var versions, latest int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*), max(version) FROM schema_migrations").Scan(&versions, &latest))
+	assert.Equal(t, len(migrations), versions)
+	assert.Equal(t, len(migrations), latest)
+}
+
+func TestMigrateClearsNullMarkers(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, `INSERT INTO movies (id, name, date) VALUES (1, 'a', '\N'), (2, 'b', ''), (3, 'c', '1977-05-25')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO movie_links (source, key, movie_id, language_iso_639_1) VALUES ('imdbmovie', 'tt1', 1, '\N'), ('imdbmovie', 'tt2', 2, 'en'), ('imdbmovie', 'tt0', 0, 'en')`)
+	require.NoError(t, err)
+
+	// testDB applied every migration already, so run the cleanup again
+	forgetMigrationsFrom(t, db, `UPDATE movies SET date = NULL`)
+	require.NoError(t, Migrate(ctx, db))
+
+	var dates []sql.NullString
+	rows, err := db.QueryContext(ctx, "SELECT date FROM movies ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var date sql.NullString
+		require.NoError(t, rows.Scan(&date))
+		dates = append(dates, date)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []sql.NullString{{}, {}, {String: "1977-05-25", Valid: true}}, dates)
+
+	var nulls int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM movie_links WHERE language_iso_639_1 IS NULL").Scan(&nulls))
+	assert.Equal(t, 1, nulls)
+
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM movie_links WHERE movie_id IS NULL").Scan(&nulls))
+	assert.Equal(t, 1, nulls)
+
+	// links without a movie can be stored
+	_, err = db.ExecContext(ctx, `INSERT INTO movie_links (source, key, movie_id) VALUES ('imdbmovie', 'tt3', NULL)`)
+	assert.NoError(t, err)
+}
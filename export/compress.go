@@ -0,0 +1,62 @@
+package export
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/dsnet/compress/bzip2"
+	"io"
+)
+
+type Compression string
+
+const (
+	None  Compression = "none"
+	Bzip2 Compression = "bzip2"
+	Gzip  Compression = "gzip"
+)
+
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case None, Bzip2, Gzip:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown compression: %q", s)
+	}
+}
+
+// Extension is the file name suffix of the compression, if any.
+func (c Compression) Extension() string {
+	switch c {
+	case Bzip2:
+		return ".bz2"
+	case Gzip:
+		return ".gz"
+	default:
+		return ""
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// NewWriter compresses everything written to it into w. Closing it flushes
+// the compressor but leaves w open.
+func (c Compression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case None, "":
+		return nopWriteCloser{w}, nil
+	case Bzip2:
+		bw, err := bzip2.NewWriter(w, nil)
+		if err != nil {
+			return nil, fmt.Errorf("bzip2.NewWriter: %w", err)
+		}
+		return bw, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %q", c)
+	}
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"io"
+)
+
+// WriteCSV writes the table as an omdb dump: a header line followed by one
+// line per row, compressed with c.
+func WriteCSV(ctx context.Context, src Source, t Table, w io.Writer, c Compression) error {
+	return encodeCSV(w, c, t.Header(), func(fn func([]any) error) error {
+		return src(ctx, t, fn)
+	})
+}
+
+// encodeCSV writes the header and every row yielded by rows to w.
+func encodeCSV(w io.Writer, c Compression, header []string, rows func(func([]any) error) error) error {
+	cw, err := c.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("NewWriter: %w", err)
+	}
+
+	writer := csv.NewWriter(cw)
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("writer.WriteHeader: %w", err)
+	}
+
+	err = rows(func(values []any) error {
+		return writer.WriteValues(values)
+	})
+	if err != nil {
+		return fmt.Errorf("rows: %w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("writer.Flush: %w", err)
+	}
+
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("cw.Close: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strconv"
+	"testing"
+)
+
+var testRows = [][]any{
+	{int64(1), "Star Wars", nil, "1977-05-25"},
+	{int64(2), `The "Quoted", Movie`, int64(1), nil},
+	{int64(3), "Multi\nline\tname", nil, `\N`},
+	{int64(4), `trailing backslash\`, nil, ""},
+	{int64(5), "", nil, "\\n is not a newline"},
+}
+
+// parseDump reads a dump with the reader the omdb importers use.
+func parseDump(t *testing.T, r io.Reader) [][]csv.Field {
+	t.Helper()
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "parent_id", "date"}, header)
+
+	var records [][]csv.Field
+	for {
+		fields, err := reader.ReadFields()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		records = append(records, fields)
+	}
+
+	return records
+}
+
+func TestEncodeCSVRoundTrip(t *testing.T) {
+	readers := map[Compression]func(io.Reader) (io.Reader, error){
+		None: func(r io.Reader) (io.Reader, error) { return r, nil },
+		Bzip2: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+		Gzip: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+
+	for compression, newReader := range readers {
+		var buf bytes.Buffer
+		err := encodeCSV(&buf, compression, Movies.Header(), func(fn func([]any) error) error {
+			for _, row := range testRows {
+				if err := fn(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		r, err := newReader(&buf)
+		require.NoError(t, err)
+
+		records := parseDump(t, r)
+		require.Len(t, records, len(testRows), "compression=%s", compression)
+
+		for i, row := range testRows {
+			want := make([]csv.Field, len(row))
+			for j, v := range row {
+				switch v := v.(type) {
+				case nil:
+					want[j] = csv.Field{Null: true}
+				case int64:
+					want[j] = csv.Field{Value: strconv.FormatInt(v, 10)}
+				case string:
+					want[j] = csv.Field{Value: v}
+				}
+			}
+
+			assert.Equal(t, want, records[i], "compression=%s", compression)
+		}
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	c, err := ParseCompression("gzip")
+	require.NoError(t, err)
+	assert.Equal(t, Gzip, c)
+	assert.Equal(t, ".gz", c.Extension())
+
+	_, err = ParseCompression("zip")
+	assert.Error(t, err)
+}
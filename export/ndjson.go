@@ -0,0 +1,141 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lsmoura/omdb-api/database"
+	"io"
+)
+
+// ndjsonRecord builds the JSON document of a row, using the same structs
+// the API serves.
+func ndjsonRecord(t Table, values []any) (any, error) {
+	if len(values) != len(t.Columns) {
+		return nil, fmt.Errorf("%d values for %d columns", len(values), len(t.Columns))
+	}
+
+	switch t.Name {
+	case Movies.Name:
+		id, err := intValue(t, values, 0)
+		if err != nil {
+			return nil, err
+		}
+		name, err := textValue(t, values, 1)
+		if err != nil {
+			return nil, err
+		}
+		parentID, err := intValue(t, values, 2)
+		if err != nil {
+			return nil, err
+		}
+		date, err := textValue(t, values, 3)
+		if err != nil {
+			return nil, err
+		}
+
+		return database.Movie{
+			ID:       *id,
+			Name:     *name,
+			ParentID: parentID,
+			Date:     date,
+		}, nil
+	case MovieLinks.Name:
+		source, err := textValue(t, values, 0)
+		if err != nil {
+			return nil, err
+		}
+		key, err := textValue(t, values, 1)
+		if err != nil {
+			return nil, err
+		}
+		movieID, err := intValue(t, values, 2)
+		if err != nil {
+			return nil, err
+		}
+		language, err := textValue(t, values, 3)
+		if err != nil {
+			return nil, err
+		}
+
+		return database.MovieLink{
+			Source:   *source,
+			Key:      *key,
+			MovieID:  movieID,
+			Language: language,
+		}, nil
+	default:
+		return nil, fmt.Errorf("no JSON document for table %q", t.Name)
+	}
+}
+
+// intValue returns the i-th value of a row, or nil for NULL. A NULL in a
+// column that is not nullable is an error, like a value of another type.
+func intValue(t Table, values []any, i int) (*int64, error) {
+	c := t.Columns[i]
+	switch v := values[i].(type) {
+	case int64:
+		return &v, nil
+	case nil:
+		if c.Nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("column %s: NULL in a column that is not nullable", c.Name)
+	default:
+		return nil, fmt.Errorf("column %s: expected int64, got %T", c.Name, v)
+	}
+}
+
+// textValue is like intValue, for text columns.
+func textValue(t Table, values []any, i int) (*string, error) {
+	c := t.Columns[i]
+	switch v := values[i].(type) {
+	case string:
+		return &v, nil
+	case nil:
+		if c.Nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("column %s: NULL in a column that is not nullable", c.Name)
+	default:
+		return nil, fmt.Errorf("column %s: expected string, got %T", c.Name, v)
+	}
+}
+
+// WriteNDJSON writes the table as one JSON document per line, compressed
+// with c. Rows are streamed, so memory use does not depend on the table
+// size.
+func WriteNDJSON(ctx context.Context, src Source, t Table, w io.Writer, c Compression) error {
+	cw, err := c.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("NewWriter: %w", err)
+	}
+
+	bw := bufio.NewWriter(cw)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+
+	err = src(ctx, t, func(values []any) error {
+		record, err := ndjsonRecord(t, values)
+		if err != nil {
+			return err
+		}
+
+		// Encode terminates every document with a newline
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return fmt.Errorf("src: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("bw.Flush: %w", err)
+	}
+
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("cw.Close: %w", err)
+	}
+
+	return nil
+}
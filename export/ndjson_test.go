@@ -0,0 +1,90 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteNDJSON(context.Background(), sliceSource(map[string][][]any{
+		"movie_links": {
+			{"imdbmovie", "tt0076759", int64(1), "en"},
+			{"imdbmovie", "tt0080684", nil, nil},
+		},
+	}), MovieLinks, &buf, None)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"source":"imdbmovie","key":"tt0076759","movie_id":1,"language_iso_639_1":"en"}`+"\n"+
+		`{"source":"imdbmovie","key":"tt0080684","movie_id":null,"language_iso_639_1":null}`+"\n", buf.String())
+}
+
+func TestWriteNDJSONInvalidRow(t *testing.T) {
+	rows := map[string][]any{
+		"null name":  {int64(1), nil, nil, nil},
+		"string id":  {"1", "Star Wars", nil, nil},
+		"short row":  {int64(1), "Star Wars"},
+		"int64 date": {int64(1), "Star Wars", nil, int64(1977)},
+	}
+
+	for name, row := range rows {
+		err := WriteNDJSON(context.Background(), sliceSource(map[string][][]any{
+			"movies": {row},
+		}), Movies, io.Discard, None)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestRoundTrip exports rows and parses them back the way the importers do.
+func TestRoundTrip(t *testing.T) {
+	src := sliceSource(map[string][][]any{"movies": testRows})
+
+	// the importer stores an empty date as NULL
+	var want [][]any
+	for _, row := range testRows {
+		row = append([]any(nil), row...)
+		if row[3] == "" {
+			row[3] = nil
+		}
+		want = append(want, row)
+	}
+
+	writers := map[omdb.Format]func(*bytes.Buffer, Compression) error{
+		omdb.FormatCSV: func(buf *bytes.Buffer, c Compression) error {
+			return WriteCSV(context.Background(), src, Movies, buf, c)
+		},
+		omdb.FormatNDJSON: func(buf *bytes.Buffer, c Compression) error {
+			return WriteNDJSON(context.Background(), src, Movies, buf, c)
+		},
+	}
+
+	d, ok := omdb.LookupDataset("all_movies")
+	require.True(t, ok)
+
+	for format, write := range writers {
+		for _, compression := range []Compression{None, Gzip, Bzip2} {
+			var buf bytes.Buffer
+			require.NoError(t, write(&buf, compression))
+
+			var got [][]any
+			err := d.ParseDump(context.Background(), bufio.NewReader(&buf), func(args []any) error {
+				values := make([]any, len(args))
+				for i, arg := range args {
+					v, err := exportValue(arg)
+					require.NoError(t, err)
+					values[i] = v
+				}
+				got = append(got, values)
+				return nil
+			}, omdb.WithFormat(format))
+			require.NoError(t, err, "format=%s compression=%s", format, compression)
+			assert.Equal(t, want, got, "format=%s compression=%s", format, compression)
+		}
+	}
+}
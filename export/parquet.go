@@ -0,0 +1,96 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"io"
+	"math"
+	"strings"
+)
+
+// DefaultParquetRowGroupSize is the number of rows per row group unless
+// told otherwise.
+const DefaultParquetRowGroupSize = 128 * 1024
+
+type ParquetOptions struct {
+	// RowGroupSize is the maximum number of rows per row group.
+	RowGroupSize int64
+}
+
+// parquetSchema describes the table in the metadata format understood by
+// the parquet writer. Nullable columns are optional, everything else is
+// required.
+func parquetSchema(t Table) []string {
+	md := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		fields := []string{"name=" + c.Name}
+		switch c.Type {
+		case Int:
+			fields = append(fields, "type=INT64")
+		case Text:
+			fields = append(fields, "type=BYTE_ARRAY", "convertedtype=UTF8")
+		}
+
+		if c.Nullable {
+			fields = append(fields, "repetitiontype=OPTIONAL")
+		} else {
+			fields = append(fields, "repetitiontype=REQUIRED")
+		}
+		md[i] = strings.Join(fields, ", ")
+	}
+
+	return md
+}
+
+// WriteParquet writes the table as a zstd compressed parquet file.
+func WriteParquet(ctx context.Context, src Source, t Table, w io.Writer, opts ParquetOptions) error {
+	rowGroupSize := opts.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultParquetRowGroupSize
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(parquetSchema(t), w, 1)
+	if err != nil {
+		return fmt.Errorf("writer.NewCSVWriterFromWriter: %w", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+	// row groups are cut by row count below, never by size
+	pw.RowGroupSize = math.MaxInt64
+
+	var rows int64
+	err = src(ctx, t, func(values []any) error {
+		record := make([]any, len(values))
+		for i, v := range values {
+			switch v.(type) {
+			case nil, int64, string:
+				record[i] = v
+			default:
+				return fmt.Errorf("%s: unsupported value type %T", t.Columns[i].Name, v)
+			}
+		}
+
+		if err := pw.Write(record); err != nil {
+			return fmt.Errorf("pw.Write: %w", err)
+		}
+
+		rows++
+		if rows%rowGroupSize == 0 {
+			if err := pw.Flush(true); err != nil {
+				return fmt.Errorf("pw.Flush: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("src: %w", err)
+	}
+
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("pw.WriteStop: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"testing"
+)
+
+type parquetMovie struct {
+	ID       int64   `parquet:"name=id, type=INT64"`
+	Name     string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ParentID *int64  `parquet:"name=parent_id, type=INT64, repetitiontype=OPTIONAL"`
+	Date     *string `parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+func TestWriteParquet(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteParquet(context.Background(), sliceSource(map[string][][]any{"movies": testRows}), Movies, &buf, ParquetOptions{RowGroupSize: 2})
+	require.NoError(t, err)
+
+	f, err := buffer.NewBufferFile(buf.Bytes())
+	require.NoError(t, err)
+
+	pr, err := reader.NewParquetReader(f, new(parquetMovie), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+
+	assert.Len(t, pr.Footer.RowGroups, (len(testRows)+1)/2)
+
+	// the first element is the root of the schema
+	for i, c := range Movies.Columns {
+		element := pr.Footer.Schema[i+1]
+		assert.Equal(t, c.Name, pr.SchemaHandler.Infos[i+1].ExName)
+		assert.Equal(t, c.Nullable, element.GetRepetitionType() == parquet.FieldRepetitionType_OPTIONAL, c.Name)
+	}
+
+	movies := make([]parquetMovie, pr.GetNumRows())
+	require.NoError(t, pr.Read(&movies))
+	require.Len(t, movies, len(testRows))
+
+	for i, row := range testRows {
+		assert.Equal(t, row[0], movies[i].ID)
+		assert.Equal(t, row[1], movies[i].Name)
+
+		if row[2] == nil {
+			assert.Nil(t, movies[i].ParentID)
+		} else {
+			require.NotNil(t, movies[i].ParentID)
+			assert.Equal(t, row[2], *movies[i].ParentID)
+		}
+
+		if row[3] == nil {
+			assert.Nil(t, movies[i].Date)
+		} else {
+			require.NotNil(t, movies[i].Date)
+			assert.Equal(t, row[3], *movies[i].Date)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lsmoura/omdb-api/omdb"
+	"io"
+)
+
+// Source streams the rows of a table to fn, with the values described in
+// Table.Rows.
+type Source func(ctx context.Context, t Table, fn func(values []any) error) error
+
+// DatabaseSource reads tables from the database.
+func DatabaseSource(db *sql.DB) Source {
+	return func(ctx context.Context, t Table, fn func([]any) error) error {
+		return t.Rows(ctx, db, fn)
+	}
+}
+
+// DumpSource reads tables straight from the omdb dumps, parsed the same way
+// the importers parse them, without any database. open returns the bzip2
+// compressed dump of a dataset.
+func DumpSource(open func(ctx context.Context, d omdb.Dataset) (io.ReadCloser, error)) Source {
+	return func(ctx context.Context, t Table, fn func([]any) error) error {
+		d, ok := omdb.LookupDataset(t.Dataset)
+		if !ok {
+			return fmt.Errorf("unknown dataset: %q", t.Dataset)
+		}
+
+		r, err := open(ctx, d)
+		if err != nil {
+			return fmt.Errorf("open: %w", err)
+		}
+		defer r.Close()
+
+		values := make([]any, len(t.Columns))
+		return d.ParseDump(ctx, r, func(args []any) error {
+			if len(args) != len(values) {
+				return fmt.Errorf("%s: got %d values, expected %d", t.Dataset, len(args), len(values))
+			}
+
+			for i, arg := range args {
+				v, err := exportValue(arg)
+				if err != nil {
+					return fmt.Errorf("%s: %w", t.Columns[i].Name, err)
+				}
+				values[i] = v
+			}
+
+			return fn(values)
+		})
+	}
+}
+
+// exportValue converts the values produced by the omdb extractors to the
+// ones produced by Table.Rows.
+func exportValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, int64, string:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case sql.NullInt64:
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.Int64, nil
+	case sql.NullString:
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.String, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
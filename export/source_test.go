@@ -0,0 +1,28 @@
+package export
+
+import (
+	"context"
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDumpSource(t *testing.T) {
+	src := DumpSource(func(ctx context.Context, d omdb.Dataset) (io.ReadCloser, error) {
+		return os.Open("../omdb/testdata/" + d.Name + ".csv.bz2")
+	})
+
+	var rows [][]any
+	err := src(context.Background(), Movies, func(values []any) error {
+		rows = append(rows, append([]any(nil), values...))
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, rows, 1200)
+	assert.Equal(t, []any{int64(7), "A title\nspanning lines", nil, "1977-05-25"}, rows[6])
+	assert.Equal(t, []any{int64(500), "Sequel, The", int64(11), "2003-01-01"}, rows[499])
+}
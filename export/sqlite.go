@@ -0,0 +1,119 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteIndexes are created once every table is loaded, which is a lot
+// faster than maintaining them while inserting.
+var sqliteIndexes = []string{
+	"CREATE INDEX movies_parent_id_idx ON movies (parent_id)",
+	"CREATE INDEX movie_links_key_idx ON movie_links (source, key)",
+	"CREATE INDEX movie_links_movie_id_idx ON movie_links (movie_id)",
+	"CREATE VIRTUAL TABLE movies_fts USING fts5 (name, content = 'movies', content_rowid = 'id')",
+	"INSERT INTO movies_fts (movies_fts) VALUES ('rebuild')",
+}
+
+func sqliteCreateTable(t Table) string {
+	columns := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		def := c.Name
+		switch c.Type {
+		case Int:
+			def += " INTEGER"
+		case Text:
+			def += " TEXT"
+		}
+		if c.PrimaryKey {
+			def += " PRIMARY KEY"
+		} else if !c.Nullable {
+			def += " NOT NULL"
+		}
+		columns[i] = def
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s)", t.Name, strings.Join(columns, ", "))
+}
+
+func sqliteInsert(t Table) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(t.Columns)), ", ")
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(t.Header(), ", "), placeholders)
+}
+
+// WriteSQLite creates a SQLite database at path holding every table, with
+// indexes for IMDb lookups and full text search on movie names. path must
+// not exist yet.
+func WriteSQLite(ctx context.Context, path string, src Source) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("sql.Open: %w", err)
+	}
+	defer db.Close()
+
+	// a single connection, so the pragmas apply to every statement
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF"} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			return fmt.Errorf("%s: %w", pragma, err)
+		}
+	}
+
+	for _, t := range Tables {
+		if err := loadSQLiteTable(ctx, db, t, src); err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+
+	for _, stmt := range sqliteIndexes {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("db.ExecContext: %w", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func loadSQLiteTable(ctx context.Context, db *sql.DB, t Table, src Source) error {
+	if _, err := db.ExecContext(ctx, sqliteCreateTable(t)); err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteInsert(t))
+	if err != nil {
+		return fmt.Errorf("tx.PrepareContext: %w", err)
+	}
+	defer stmt.Close()
+
+	err = src(ctx, t, func(values []any) error {
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("stmt.ExecContext: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("src: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func sliceSource(rows map[string][][]any) Source {
+	return func(ctx context.Context, t Table, fn func([]any) error) error {
+		for _, row := range rows[t.Name] {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.sqlite")
+
+	err := WriteSQLite(context.Background(), path, sliceSource(map[string][][]any{
+		"movies": testRows,
+		"movie_links": {
+			{"imdbmovie", "tt0076759", int64(1), "en"},
+			{"wikipedia", "Star_Wars", int64(1), "en"},
+		},
+	}))
+	require.NoError(t, err)
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var name string
+	row := db.QueryRow("SELECT m.name FROM movie_links l JOIN movies m ON m.id = l.movie_id WHERE l.source = 'imdbmovie' AND l.key = ?", "tt0076759")
+	require.NoError(t, row.Scan(&name))
+	assert.Equal(t, "Star Wars", name)
+
+	var id int64
+	require.NoError(t, db.QueryRow("SELECT rowid FROM movies_fts WHERE movies_fts MATCH 'quoted'").Scan(&id))
+	assert.EqualValues(t, 2, id)
+
+	var parentID sql.NullInt64
+	require.NoError(t, db.QueryRow("SELECT parent_id FROM movies WHERE id = 1").Scan(&parentID))
+	assert.False(t, parentID.Valid)
+
+	// the file must not be overwritten
+	assert.Error(t, WriteSQLite(context.Background(), path, sliceSource(nil)))
+}
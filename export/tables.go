@@ -0,0 +1,129 @@
+// Package export writes the catalog back out of the database, in formats
+// other tools (and our own importers) can read.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type ColumnType int
+
+const (
+	Int ColumnType = iota
+	Text
+)
+
+type Column struct {
+	Name       string
+	Type       ColumnType
+	Nullable   bool
+	PrimaryKey bool
+}
+
+// Table is an exportable table. Its columns are named and ordered like the
+// omdb dump of the same dataset.
+type Table struct {
+	Name string
+	// Dataset is the name of the omdb dump the table is imported from.
+	Dataset string
+	Columns []Column
+	query   string
+}
+
+var (
+	Movies = Table{
+		Name:    "movies",
+		Dataset: "all_movies",
+		Columns: []Column{
+			{Name: "id", Type: Int, PrimaryKey: true},
+			{Name: "name", Type: Text},
+			{Name: "parent_id", Type: Int, Nullable: true},
+			{Name: "date", Type: Text, Nullable: true},
+		},
+		query: "SELECT id, name, parent_id, date FROM movies WHERE deleted_at IS NULL ORDER BY id",
+	}
+	MovieLinks = Table{
+		Name:    "movie_links",
+		Dataset: "movie_links",
+		Columns: []Column{
+			{Name: "source", Type: Text},
+			{Name: "key", Type: Text},
+			{Name: "movie_id", Type: Int, Nullable: true},
+			{Name: "language_iso_639_1", Type: Text, Nullable: true},
+		},
+		query: "SELECT source, key, movie_id, language_iso_639_1 FROM movie_links ORDER BY source, key, movie_id",
+	}
+)
+
+var Tables = []Table{Movies, MovieLinks}
+
+func LookupTable(dataset string) (Table, bool) {
+	for _, t := range Tables {
+		if t.Dataset == dataset {
+			return t, true
+		}
+	}
+
+	return Table{}, false
+}
+
+// Header returns the column names.
+func (t Table) Header() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+
+	return names
+}
+
+// Rows streams every row of the table to fn. Values are int64 or string,
+// or nil for NULL. The slice is reused between calls.
+func (t Table) Rows(ctx context.Context, db *sql.DB, fn func(values []any) error) error {
+	rows, err := db.QueryContext(ctx, t.query)
+	if err != nil {
+		return fmt.Errorf("db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	ints := make([]sql.NullInt64, len(t.Columns))
+	texts := make([]sql.NullString, len(t.Columns))
+	dest := make([]any, len(t.Columns))
+	for i, c := range t.Columns {
+		switch c.Type {
+		case Int:
+			dest[i] = &ints[i]
+		case Text:
+			dest[i] = &texts[i]
+		}
+	}
+
+	values := make([]any, len(t.Columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("rows.Scan: %w", err)
+		}
+
+		for i, c := range t.Columns {
+			values[i] = nil
+			switch {
+			case c.Type == Int && ints[i].Valid:
+				values[i] = ints[i].Int64
+			case c.Type == Text && texts[i].Valid:
+				values[i] = texts[i].String
+			}
+		}
+
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+// Package jobs runs imports in the background. Jobs are queued in the
+// import_jobs table, so they can be enqueued by an HTTP handler and picked up
+// by a worker running anywhere else.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/lsmoura/omdb-api/omdb"
+	"strconv"
+	"time"
+)
+
+// AllDatasets is the dataset of jobs that import everything, in dependency
+// order, with omdb.ImportAll.
+const AllDatasets = "all"
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// Params are the import options of a job. They are stored as JSON, since the
+// job may run in a different process than the one that enqueued it.
+type Params struct {
+	MissingPolicy omdb.MissingPolicy `json:"missing_policy,omitempty"`
+	Lenient       bool               `json:"lenient,omitempty"`
+	ErrorBudget   int                `json:"error_budget,omitempty"`
+	WaitForLock   bool               `json:"wait_for_lock,omitempty"`
+	StrictHeader  bool               `json:"strict_header,omitempty"`
+	Normalization omdb.Normalization `json:"normalization,omitempty"`
+}
+
+// SetBudget parses the budget parameter of the import endpoints. A budget
+// switches the import to lenient mode, an empty one leaves it strict.
+func (p *Params) SetBudget(budget string) error {
+	if budget == "" {
+		return nil
+	}
+
+	errorBudget, err := strconv.Atoi(budget)
+	if err != nil {
+		return fmt.Errorf("invalid budget: %w", err)
+	}
+	p.Lenient = true
+	p.ErrorBudget = errorBudget
+
+	return nil
+}
+
+// Options turns the params into the options of an import.
+func (p Params) Options() []omdb.ImportOption {
+	var opts []omdb.ImportOption
+	if p.MissingPolicy != "" {
+		opts = append(opts, omdb.WithMissingPolicy(p.MissingPolicy))
+	}
+	if p.Lenient {
+		opts = append(opts, omdb.WithLenient(p.ErrorBudget))
+	}
+	if p.WaitForLock {
+		opts = append(opts, omdb.WithWaitForLock())
+	}
+	if p.StrictHeader {
+		opts = append(opts, omdb.WithStrictHeader())
+	}
+	if p.Normalization != "" {
+		opts = append(opts, omdb.WithNormalization(p.Normalization))
+	}
+
+	return opts
+}
+
+type Job struct {
+	ID         int64      `json:"id"`
+	Dataset    string     `json:"dataset"`
+	Params     Params     `json:"params"`
+	State      State      `json:"state"`
+	Rows       int64      `json:"rows"`
+	Rejected   int64      `json:"rejected"`
+	BytesRead  int64      `json:"bytes_read"`
+	TotalBytes int64      `json:"total_bytes"`
+	Error      *string    `json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+const jobColumns = "id, dataset, params, state, rows, rejected, bytes_read, total_bytes, error, created_at, updated_at, started_at, finished_at"
+
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	var job Job
+	var params []byte
+	if err := row.Scan(&job.ID, &job.Dataset, &params, &job.State, &job.Rows, &job.Rejected, &job.BytesRead, &job.TotalBytes, &job.Error, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt); err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+
+	if err := json.Unmarshal(params, &job.Params); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return &job, nil
+}
+
+// enqueueLockKey is the key of the advisory lock serializing Enqueue.
+const enqueueLockKey = 0x6f6d64622d6a6f62 // "omdb-job"
+
+// overlapping returns the datasets whose jobs import some of the same
+// tables as a job of dataset, dataset included.
+func overlapping(dataset string) []string {
+	if dataset == AllDatasets {
+		names := []string{AllDatasets}
+		for _, d := range omdb.Datasets {
+			names = append(names, d.Name)
+		}
+		return names
+	}
+
+	return []string{dataset, AllDatasets}
+}
+
+// Enqueue queues an import of dataset and returns the job ID. It fails with
+// omdb.ErrImportRunning if a pending job imports any of the same datasets,
+// such as a job of every dataset for a job of all_movies.
+func Enqueue(ctx context.Context, db *sql.DB, dataset string, params Params) (int64, error) {
+	if _, ok := omdb.LookupDataset(dataset); !ok && dataset != AllDatasets {
+		return 0, fmt.Errorf("unknown dataset: %q", dataset)
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// two enqueues could both find nothing pending otherwise
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", enqueueLockKey); err != nil {
+		return 0, fmt.Errorf("pg_advisory_xact_lock: %w", err)
+	}
+
+	var pending string
+	err = tx.QueryRowContext(ctx, "SELECT dataset FROM import_jobs WHERE state IN ($1, $2) AND dataset = ANY($3) LIMIT 1",
+		StateQueued, StateRunning, pq.Array(overlapping(dataset))).Scan(&pending)
+	if err == nil {
+		return 0, fmt.Errorf("%s: %w: %s", dataset, omdb.ErrImportRunning, pending)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("tx.QueryRowContext: %w", err)
+	}
+
+	// import_jobs_active_idx still allows a single queued or running job per
+	// dataset, should a job be inserted some other way
+	var id int64
+	row := tx.QueryRowContext(ctx, "INSERT INTO import_jobs (dataset, params) VALUES ($1, $2) RETURNING id", dataset, encoded)
+	if err := row.Scan(&id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return 0, fmt.Errorf("%s: %w", dataset, omdb.ErrImportRunning)
+		}
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	return id, nil
+}
+
+// Get returns the job with the given ID, or sql.ErrNoRows.
+func Get(ctx context.Context, db *sql.DB, id int64) (*Job, error) {
+	row := db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM import_jobs WHERE id = $1", id)
+
+	return scanJob(row)
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestParamsJSON(t *testing.T) {
+	params := Params{
+		MissingPolicy: omdb.MissingSoftDelete,
+		Lenient:       true,
+		ErrorBudget:   10,
+	}
+
+	encoded, err := json.Marshal(params)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"missing_policy":"soft-delete","lenient":true,"error_budget":10}`, string(encoded))
+
+	var decoded Params
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, params, decoded)
+	assert.Len(t, decoded.Options(), 2)
+
+	empty, err := json.Marshal(Params{})
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(empty))
+}
+
+func TestParamsSetBudget(t *testing.T) {
+	var params Params
+	require.NoError(t, params.SetBudget(""))
+	assert.Equal(t, Params{}, params)
+
+	require.NoError(t, params.SetBudget("25"))
+	assert.Equal(t, Params{Lenient: true, ErrorBudget: 25}, params)
+
+	assert.Error(t, params.SetBudget("many"))
+}
+
+func TestOverlapping(t *testing.T) {
+	assert.ElementsMatch(t, []string{AllDatasets, "all_movies", "movie_links"}, overlapping(AllDatasets))
+	assert.ElementsMatch(t, []string{"all_movies", AllDatasets}, overlapping("all_movies"))
+}
+
+// testDB connects to the scratch database named by TEST_DATABASE_URL, whose
+// jobs are wiped, and skips the test when there is none.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connURL := os.Getenv("TEST_DATABASE_URL")
+	if connURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", connURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS movies (
+			id BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			parent_id BIGINT,
+			date TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS movie_links (
+			source TEXT NOT NULL,
+			key TEXT NOT NULL,
+			movie_id BIGINT NOT NULL,
+			language_iso_639_1 TEXT
+		)`,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.Migrate(ctx, db))
+
+	_, err = db.ExecContext(ctx, "TRUNCATE import_jobs")
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestEnqueueOverlapping(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	_, err := Enqueue(ctx, db, AllDatasets, Params{})
+	require.NoError(t, err)
+
+	// every dataset is part of the pending job
+	for _, dataset := range []string{"all_movies", "movie_links", AllDatasets} {
+		_, err = Enqueue(ctx, db, dataset, Params{})
+		assert.ErrorIs(t, err, omdb.ErrImportRunning, dataset)
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE import_jobs SET state = $1", StateSucceeded)
+	require.NoError(t, err)
+
+	_, err = Enqueue(ctx, db, "all_movies", Params{})
+	require.NoError(t, err)
+	_, err = Enqueue(ctx, db, AllDatasets, Params{})
+	assert.ErrorIs(t, err, omdb.ErrImportRunning)
+	// datasets do not overlap each other
+	_, err = Enqueue(ctx, db, "movie_links", Params{})
+	assert.NoError(t, err)
+}
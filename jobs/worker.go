@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/logging"
+	"github.com/lsmoura/omdb-api/omdb"
+	"golang.org/x/exp/slog"
+	"time"
+)
+
+const (
+	// progressUpdateInterval is how often a running job writes its progress,
+	// which doubles as its heartbeat.
+	progressUpdateInterval = 5 * time.Second
+	// staleAfter is how long a running job may go without a heartbeat
+	// before another worker assumes it died and picks it up again.
+	staleAfter = 10 * time.Minute
+)
+
+// claim marks the oldest runnable job as running and returns it, or
+// sql.ErrNoRows when there is nothing to do.
+func claim(ctx context.Context, db *sql.DB) (*Job, error) {
+	query := `UPDATE import_jobs SET state = $1, started_at = now(), updated_at = now(), error = NULL
+		WHERE id = (
+			SELECT id FROM import_jobs
+			WHERE state = $2 OR (state = $1 AND updated_at < now() - $3::interval)
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	row := db.QueryRowContext(ctx, query, StateRunning, StateQueued, fmt.Sprintf("%d seconds", int(staleAfter.Seconds())))
+
+	return scanJob(row)
+}
+
+func finish(ctx context.Context, db *sql.DB, id int64, jobErr error) error {
+	state := StateSucceeded
+	var message *string
+	if jobErr != nil {
+		state = StateFailed
+		s := jobErr.Error()
+		message = &s
+	}
+
+	const query = "UPDATE import_jobs SET state = $2, error = $3, finished_at = now(), updated_at = now() WHERE id = $1"
+	if _, err := db.ExecContext(ctx, query, id, state, message); err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func updateProgress(ctx context.Context, db *sql.DB, id int64, p omdb.Progress) error {
+	const query = "UPDATE import_jobs SET rows = $2, rejected = $3, bytes_read = $4, total_bytes = $5, updated_at = now() WHERE id = $1"
+	if _, err := db.ExecContext(ctx, query, id, p.Rows, p.Rejected, p.BytesRead, p.TotalBytes); err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func run(ctx context.Context, db *sql.DB, job *Job) error {
+	importFn := omdb.ImportAll
+	if job.Dataset != AllDatasets {
+		dataset, ok := omdb.LookupDataset(job.Dataset)
+		if !ok {
+			return fmt.Errorf("unknown dataset: %q", job.Dataset)
+		}
+		importFn = dataset.Import
+	}
+
+	// changes are recorded under the job, so they can be traced back to it
+	opts := append(job.Params.Options(), omdb.WithRunID(fmt.Sprintf("job-%d", job.ID)), omdb.WithProgress(func(p omdb.Progress) {
+		if err := updateProgress(ctx, db, job.ID, p); err != nil {
+			logging.LoggerFromContext(ctx).Error("updateProgress", "error", err)
+		}
+	}, progressUpdateInterval))
+
+	return importFn(ctx, db, opts...)
+}
+
+// RunOnce runs the next queued job, if any. It reports whether a job was
+// found; the outcome of the job itself is stored in the job, not returned.
+func RunOnce(ctx context.Context, db *sql.DB) (bool, error) {
+	job, err := claim(ctx, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim: %w", err)
+	}
+
+	baseLogger := logging.LoggerFromContext(ctx)
+	if baseLogger == nil {
+		baseLogger = slog.Default()
+	}
+	ctx = logging.WithLogger(ctx, baseLogger, "job", job.ID, "dataset", job.Dataset)
+	logger := logging.LoggerFromContext(ctx)
+	logger.Info("job started")
+
+	jobErr := run(ctx, db, job)
+	if jobErr != nil {
+		logger.Error("job failed", "error", jobErr)
+	} else {
+		logger.Info("job succeeded")
+	}
+
+	// the job context may be gone already, but the outcome must be stored
+	if err := finish(context.Background(), db, job.ID, jobErr); err != nil {
+		return true, fmt.Errorf("finish: %w", err)
+	}
+
+	return true, nil
+}
+
+// Drain runs queued jobs until there are none left.
+func Drain(ctx context.Context, db *sql.DB) error {
+	for {
+		found, err := RunOnce(ctx, db)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+	}
+}
+
+// Work runs queued jobs until ctx is done, polling for new ones every
+// pollInterval once the queue is empty.
+func Work(ctx context.Context, db *sql.DB, pollInterval time.Duration) error {
+	for {
+		if err := Drain(ctx, db); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if logger := logging.LoggerFromContext(ctx); logger != nil {
+				logger.Error("jobs.Drain", "error", err)
+			}
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
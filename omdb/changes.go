@@ -0,0 +1,30 @@
+package omdb
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// newRunID returns a random identifier for an import run, used when the
+// caller did not provide one with WithRunID.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("rand.Read: %s", err))
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// setImportRun tags every change made by tx with runID. The triggers on the
+// catalog tables copy it into catalog_changes.
+func setImportRun(ctx context.Context, tx *sql.Tx, runID string) error {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('omdb.import_run', $1, true)", runID); err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/lsmoura/omdb-api/logging"
+	"io"
+)
+
+// Dataset is one of the omdb dumps we know how to import.
+type Dataset struct {
+	Name string
+	URL  string
+	// Columns are the columns of the dump, in order.
+	Columns []string
+	Import  func(ctx context.Context, db *sql.DB, opts ...ImportOption) error
+	// DependsOn lists the datasets whose tables this one references. They
+	// are imported first by ImportAll.
+	DependsOn []string
+
+	extractor func([]csv.Field) ([]any, error)
+}
+
+var Datasets = []Dataset{
+	{
+		Name:      "all_movies",
+		URL:       AllMoviesURL,
+		Columns:   allMoviesColumns,
+		Import:    ImportAllMovies,
+		extractor: allMoviesFieldsToArgs,
+	},
+	{
+		Name:      "movie_links",
+		URL:       MovieLinksURL,
+		Columns:   movieLinksColumns,
+		Import:    ImportMovieLinks,
+		DependsOn: []string{"all_movies"},
+		extractor: movieLinksFieldsToArgs,
+	},
+}
+
+// ErrUpstreamFailed is reported for datasets ImportAll skipped because one
+// of their dependencies failed to import.
+var ErrUpstreamFailed = errors.New("upstream dataset failed")
+
+func LookupDataset(name string) (Dataset, bool) {
+	for _, d := range Datasets {
+		if d.Name == name {
+			return d, true
+		}
+	}
+
+	return Dataset{}, false
+}
+
+// ResolveOrder sorts datasets so every one comes after its dependencies,
+// otherwise keeping their original order.
+func ResolveOrder(datasets []Dataset) ([]Dataset, error) {
+	byName := make(map[string]Dataset, len(datasets))
+	for _, d := range datasets {
+		byName[d.Name] = d
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(datasets))
+	ordered := make([]Dataset, 0, len(datasets))
+
+	var visit func(d Dataset, path []string) error
+	visit = func(d Dataset, path []string) error {
+		switch state[d.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", append(path, d.Name))
+		}
+
+		state[d.Name] = visiting
+		for _, name := range d.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%s: unknown dependency %q", d.Name, name)
+			}
+			if err := visit(dep, append(path, d.Name)); err != nil {
+				return err
+			}
+		}
+		state[d.Name] = visited
+		ordered = append(ordered, d)
+
+		return nil
+	}
+
+	for _, d := range datasets {
+		if err := visit(d, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// ImportAll imports every known dataset in dependency order. A dataset that
+// fails makes everything depending on it, directly or not, be skipped, while
+// unrelated datasets are still imported. The returned error joins every
+// failure. The changes of all datasets are recorded under one import run.
+func ImportAll(ctx context.Context, db *sql.DB, opts ...ImportOption) error {
+	return importDatasets(ctx, db, Datasets, opts)
+}
+
+func importDatasets(ctx context.Context, db *sql.DB, datasets []Dataset, opts []ImportOption) error {
+	ordered, err := ResolveOrder(datasets)
+	if err != nil {
+		return fmt.Errorf("ResolveOrder: %w", err)
+	}
+
+	// record the changes of every dataset under the same run
+	if newImportOptions(opts).runID == "" {
+		opts = append(opts, WithRunID(newRunID()))
+	}
+
+	logger := logging.LoggerFromContext(ctx)
+
+	failed := make(map[string]bool)
+	var errs []error
+	for _, d := range ordered {
+		var upstream string
+		for _, dep := range d.DependsOn {
+			if failed[dep] {
+				upstream = dep
+				break
+			}
+		}
+
+		if upstream != "" {
+			failed[d.Name] = true
+			errs = append(errs, fmt.Errorf("%s: %w: %s", d.Name, ErrUpstreamFailed, upstream))
+			if logger != nil {
+				logger.Warn("ImportAll: skipped", "dataset", d.Name, "upstream", upstream)
+			}
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		if err := d.Import(ctx, db, opts...); err != nil {
+			failed[d.Name] = true
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name, err))
+			if logger != nil {
+				logger.Error("ImportAll: failed", "dataset", d.Name, "error", err)
+			}
+			continue
+		}
+
+		if logger != nil {
+			logger.Info("ImportAll: imported", "dataset", d.Name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseDump parses a dump of d without touching the database, handing every
+// row to fn in file order. The row holds the same values the importer would
+// insert. The dump is a CSV, compressed or not, unless WithFormat says
+// otherwise.
+func (d Dataset) ParseDump(ctx context.Context, r io.Reader, fn func(args []any) error, opts ...ImportOption) error {
+	options := newImportOptions(opts)
+
+	p := startPipeline(ctx, r, options.recordFormat(d.Columns), d.extractor, options.workers)
+	for result := range p.results {
+		rows, err := p.wait(ctx, result)
+		if err != nil {
+			p.Close()
+			return fmt.Errorf("pipeline: %w", err)
+		}
+
+		for _, row := range rows {
+			if row.err != nil {
+				p.Close()
+				return fmt.Errorf("line %d: %w", row.line, row.err)
+			}
+
+			if err := fn(row.args); err != nil {
+				p.Close()
+				return err
+			}
+		}
+	}
+
+	if err := p.Close(); err != nil {
+		return fmt.Errorf("pipeline: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func datasetNames(datasets []Dataset) []string {
+	names := make([]string, len(datasets))
+	for i, d := range datasets {
+		names[i] = d.Name
+	}
+
+	return names
+}
+
+func TestResolveOrder(t *testing.T) {
+	ordered, err := ResolveOrder([]Dataset{
+		{Name: "casts", DependsOn: []string{"people", "all_movies"}},
+		{Name: "movie_links", DependsOn: []string{"all_movies"}},
+		{Name: "all_movies"},
+		{Name: "people"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"people", "all_movies", "casts", "movie_links"}, datasetNames(ordered))
+
+	ordered, err = ResolveOrder(Datasets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"all_movies", "movie_links"}, datasetNames(ordered))
+}
+
+func TestResolveOrderErrors(t *testing.T) {
+	_, err := ResolveOrder([]Dataset{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	})
+	assert.ErrorContains(t, err, "dependency cycle")
+
+	_, err = ResolveOrder([]Dataset{
+		{Name: "a", DependsOn: []string{"missing"}},
+	})
+	assert.ErrorContains(t, err, "unknown dependency")
+}
+
+func TestImportDatasetsStopsDownstream(t *testing.T) {
+	var imported []string
+	importer := func(name string, err error) func(context.Context, *sql.DB, ...ImportOption) error {
+		return func(context.Context, *sql.DB, ...ImportOption) error {
+			imported = append(imported, name)
+			return err
+		}
+	}
+
+	boom := errors.New("boom")
+	err := importDatasets(context.Background(), nil, []Dataset{
+		{Name: "movies", Import: importer("movies", boom)},
+		{Name: "links", Import: importer("links", nil), DependsOn: []string{"movies"}},
+		{Name: "casts", Import: importer("casts", nil), DependsOn: []string{"links", "people"}},
+		{Name: "people", Import: importer("people", nil)},
+	}, nil)
+
+	assert.Equal(t, []string{"movies", "people"}, imported)
+	assert.ErrorIs(t, err, boom)
+	assert.ErrorIs(t, err, ErrUpstreamFailed)
+	assert.ErrorContains(t, err, "casts")
+}
+
+func TestImportDatasetsSharesRunID(t *testing.T) {
+	var runIDs []string
+	importer := func(_ context.Context, _ *sql.DB, opts ...ImportOption) error {
+		runIDs = append(runIDs, newImportOptions(opts).runID)
+		return nil
+	}
+
+	datasets := []Dataset{
+		{Name: "movies", Import: importer},
+		{Name: "links", Import: importer, DependsOn: []string{"movies"}},
+	}
+
+	require.NoError(t, importDatasets(context.Background(), nil, datasets, nil))
+	require.Len(t, runIDs, 2)
+	assert.NotEmpty(t, runIDs[0])
+	assert.Equal(t, runIDs[0], runIDs[1])
+
+	runIDs = nil
+	require.NoError(t, importDatasets(context.Background(), nil, datasets, []ImportOption{WithRunID("job-1")}))
+	assert.Equal(t, []string{"job-1", "job-1"}, runIDs)
+}
+
+func TestParseDump(t *testing.T) {
+	f, err := os.Open("testdata/all_movies.csv.bz2")
+	require.NoError(t, err)
+	defer f.Close()
+
+	d, ok := LookupDataset("all_movies")
+	require.True(t, ok)
+
+	var ids []int
+	err = d.ParseDump(context.Background(), f, func(args []any) error {
+		ids = append(ids, args[0].(int))
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ids, 1200)
+	assert.Equal(t, 1, ids[0])
+	assert.Equal(t, 1200, ids[1199])
+}
@@ -0,0 +1,50 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lsmoura/omdb-api/database"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// testDB connects to the scratch database named by TEST_DATABASE_URL, which
+// is wiped, and skips the test when there is none.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connURL := os.Getenv("TEST_DATABASE_URL")
+	if connURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", connURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS movies (
+			id BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			parent_id BIGINT,
+			date TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS movie_links (
+			source TEXT NOT NULL,
+			key TEXT NOT NULL,
+			movie_id BIGINT NOT NULL,
+			language_iso_639_1 TEXT
+		)`,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.Migrate(ctx, db))
+
+	_, err = db.ExecContext(ctx, "TRUNCATE movies, movie_links, catalog_changes")
+	require.NoError(t, err)
+
+	return db
+}
@@ -0,0 +1,307 @@
+package omdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fetcher downloads dumps, retrying transient failures with exponential
+// backoff and resuming interrupted transfers with Range requests when the
+// server supports them.
+type Fetcher struct {
+	Client    *http.Client
+	UserAgent string
+	// MaxRetries is how many times a failed request is retried, both when
+	// starting the download and when resuming it.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// IdleTimeout is how long a read of the body may go without data before
+	// the connection is dropped and the download resumed. Zero disables it.
+	IdleTimeout time.Duration
+}
+
+// NewFetcher returns a Fetcher with sensible defaults. The client has no
+// overall timeout, since reading a full dump can take a while, but it gives
+// up on responses that stall, before the headers or in the middle of the
+// body.
+func NewFetcher() *Fetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &Fetcher{
+		Client:      &http.Client{Transport: transport},
+		UserAgent:   "omdb-api",
+		MaxRetries:  5,
+		MinBackoff:  time.Second,
+		MaxBackoff:  30 * time.Second,
+		IdleTimeout: time.Minute,
+	}
+}
+
+// retryableError marks a failure worth retrying.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	d := f.MinBackoff << attempt
+	if d <= 0 || d > f.MaxBackoff {
+		d = f.MaxBackoff
+	}
+
+	// full jitter keeps concurrent clients from retrying in lockstep
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// do runs fn until it succeeds, fails with a permanent error or runs out of
+// retries.
+func (f *Fetcher) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= f.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(f.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// request issues a GET for url starting at offset. A non-empty validator
+// (ETag or Last-Modified) makes the server restart from scratch if the file
+// changed since the first response.
+func (f *Fetcher) request(ctx context.Context, url string, offset int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %w", err)
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &retryableError{fmt.Errorf("client.Do: %w", err)}
+	}
+
+	expected := http.StatusOK
+	if offset > 0 {
+		expected = http.StatusPartialContent
+	}
+	if resp.StatusCode == expected {
+		if offset > 0 {
+			prefix := "bytes " + strconv.FormatInt(offset, 10) + "-"
+			if contentRange := resp.Header.Get("Content-Range"); !strings.HasPrefix(contentRange, prefix) {
+				resp.Body.Close()
+				return nil, fmt.Errorf("unexpected content range: %q", contentRange)
+			}
+		}
+
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	err = fmt.Errorf("http status: %d", resp.StatusCode)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &retryableError{err}
+	}
+
+	return nil, err
+}
+
+// Fetch starts downloading url. The returned Download must be closed.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (*Download, error) {
+	var resp *http.Response
+	err := f.do(ctx, func() error {
+		var err error
+		resp, err = f.request(ctx, url, 0, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Download{
+		ctx:     ctx,
+		fetcher: f,
+		url:     url,
+		body:    resp.Body,
+		Size:    resp.ContentLength,
+	}
+
+	// only resume when we can make sure the file did not change meanwhile
+	if resp.Header.Get("Accept-Ranges") == "bytes" {
+		d.validator = resp.Header.Get("ETag")
+		if d.validator == "" {
+			d.validator = resp.Header.Get("Last-Modified")
+		}
+		d.resumable = d.validator != ""
+	}
+
+	return d, nil
+}
+
+// idleTimeoutError is returned by a read of the body that got no data for
+// Fetcher.IdleTimeout. It is a net.Error, so the download resumes.
+type idleTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e idleTimeoutError) Error() string {
+	return fmt.Sprintf("no data received for %s", e.timeout)
+}
+func (idleTimeoutError) Timeout() bool   { return true }
+func (idleTimeoutError) Temporary() bool { return true }
+
+// errDownloadClosed is returned by reads of a closed Download.
+var errDownloadClosed = errors.New("download closed")
+
+// Download is the body of a dump being fetched. Reads that fail halfway are
+// resumed transparently from where they stopped when possible. Close may be
+// called while a Read is blocked, to interrupt it.
+type Download struct {
+	// Size is the Content-Length of the dump, or -1 if unknown.
+	Size int64
+
+	ctx       context.Context
+	fetcher   *Fetcher
+	url       string
+	offset    int64
+	resumable bool
+	validator string
+	resumes   int
+
+	// mu guards body, which resume replaces, and closed.
+	mu     sync.Mutex
+	body   io.ReadCloser
+	closed bool
+}
+
+func (d *Download) Read(p []byte) (int, error) {
+	for {
+		d.mu.Lock()
+		body, closed := d.body, d.closed
+		d.mu.Unlock()
+		if closed {
+			return 0, errDownloadClosed
+		}
+
+		n, err := d.readBody(body, p)
+		d.offset += int64(n)
+		if err == nil || err == io.EOF || !d.canResume(err) {
+			return n, err
+		}
+
+		if resumeErr := d.resume(); resumeErr != nil {
+			return n, fmt.Errorf("%w (resume: %s)", err, resumeErr)
+		}
+
+		// a read that failed before any data keeps going on the new body,
+		// rather than returning nothing
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// readBody reads from body, closing it when no data comes for the idle
+// timeout of the fetcher. A stalled connection would block forever
+// otherwise.
+func (d *Download) readBody(body io.Reader, p []byte) (int, error) {
+	timeout := d.fetcher.IdleTimeout
+	if timeout <= 0 {
+		return body.Read(p)
+	}
+
+	var idle atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		idle.Store(true)
+		if c, ok := body.(io.Closer); ok {
+			c.Close()
+		}
+	})
+	n, err := body.Read(p)
+	if !timer.Stop() && idle.Load() {
+		return n, idleTimeoutError{timeout}
+	}
+
+	return n, err
+}
+
+func (d *Download) canResume(err error) bool {
+	if !d.resumable || d.ctx.Err() != nil || d.resumes >= d.fetcher.MaxRetries {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+func (d *Download) resume() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errDownloadClosed
+	}
+	d.body.Close()
+	d.mu.Unlock()
+	d.resumes++
+
+	return d.fetcher.do(d.ctx, func() error {
+		resp, err := d.fetcher.request(d.ctx, d.url, d.offset, d.validator)
+		if err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// Close ran while the request was in flight
+		if d.closed {
+			resp.Body.Close()
+			return errDownloadClosed
+		}
+		d.body = resp.Body
+
+		return nil
+	})
+}
+
+func (d *Download) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	return d.body.Close()
+}
@@ -0,0 +1,217 @@
+package omdb
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testFetcher() *Fetcher {
+	f := NewFetcher()
+	f.MinBackoff = time.Millisecond
+	f.MaxBackoff = time.Millisecond
+
+	return f
+}
+
+func TestFetcherRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "omdb-api", r.Header.Get("User-Agent"))
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	d, err := testFetcher().Fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer d.Close()
+
+	body, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestFetcherDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := testFetcher().Fetch(context.Background(), server.URL)
+	assert.ErrorContains(t, err, "http status: 404")
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestFetcherGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	f := testFetcher()
+	f.MaxRetries = 2
+
+	_, err := f.Fetch(context.Background(), server.URL)
+	assert.ErrorContains(t, err, "http status: 502")
+}
+
+func TestFetcherResumes(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+
+		if calls.Add(1) == 1 {
+			// advertise the whole file, send half of it and hang up
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "10000")
+			w.WriteHeader(http.StatusOK)
+			w.Write(content[:5000])
+
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+
+		assert.Equal(t, "bytes=5000-", r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	d, err := testFetcher().Fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer d.Close()
+
+	assert.EqualValues(t, 10000, d.Size)
+
+	body, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, content, body)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+// failingBody fails its first read with err, before any data.
+type failingBody struct {
+	err error
+}
+
+func (b failingBody) Read([]byte) (int, error) { return 0, b.err }
+func (b failingBody) Close() error             { return nil }
+
+func TestDownloadReadsAfterResume(t *testing.T) {
+	content := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	d := &Download{
+		ctx:       context.Background(),
+		fetcher:   testFetcher(),
+		url:       server.URL,
+		body:      failingBody{io.ErrUnexpectedEOF},
+		offset:    4,
+		resumable: true,
+		validator: `"v1"`,
+	}
+	defer d.Close()
+
+	// the read that failed is retried on the resumed body
+	p := make([]byte, 16)
+	n, err := d.Read(p)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "456789", string(p[:n]))
+}
+
+func TestDownloadCloseInterruptsRead(t *testing.T) {
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "10000")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("0123456789"))
+		w.(http.Flusher).Flush()
+
+		// stall until the test is over
+		<-stop
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	d, err := testFetcher().Fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := io.ReadAll(d)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, d.Close())
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read was not interrupted by Close")
+	}
+}
+
+func TestFetcherResumesStalledBody(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+
+	stop := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+
+		if calls.Add(1) == 1 {
+			// send half of the file and stop writing, without hanging up
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "10000")
+			w.WriteHeader(http.StatusOK)
+			w.Write(content[:5000])
+			w.(http.Flusher).Flush()
+
+			<-stop
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	f := testFetcher()
+	f.IdleTimeout = 50 * time.Millisecond
+	d, err := f.Fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer d.Close()
+
+	body, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, content, body)
+	assert.EqualValues(t, 2, calls.Load())
+}
@@ -0,0 +1,159 @@
+package omdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"io"
+)
+
+// Format is the encoding of an import input.
+type Format string
+
+const (
+	// FormatCSV is an omdb dump, as published by omdb or written by the
+	// export package, compressed with bzip2 or gzip or not at all. Its dialect is csv.MySQL unless
+	// WithDialect says otherwise.
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one JSON object per line, keyed by column name, as
+	// written by the export package.
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatNDJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format: %q", s)
+	}
+}
+
+// recordFormat tells the pipeline how to turn an input into fields.
+type recordFormat struct {
+	decompress func(io.Reader) io.Reader
+	// header is set when the first line holds the column names.
+	header bool
+	// columns are the columns the dataset expects the header to name, in
+	// the order the extractor wants them.
+	columns []string
+	// strictHeader fails the import when the header differs from columns
+	// in any way, instead of mapping the columns by name.
+	strictHeader bool
+	// dialect is how records are delimited and quoted.
+	dialect csv.Dialect
+	// continuations is set when a line ending in a backslash continues on
+	// the next one.
+	continuations bool
+	fields        func(record string) ([]csv.Field, error)
+	// newFields, when set, is used instead of fields to give each parse
+	// worker its own split function, which may reuse its result between
+	// calls.
+	newFields func() func(record string) ([]csv.Field, error)
+	// normalize, when set, checks and rewrites the text fields of every
+	// record in place.
+	normalize func([]csv.Field) error
+}
+
+// splitterFields returns a newFields function handing out a csv.Splitter
+// per worker.
+func splitterFields(d csv.Dialect) func() func(string) ([]csv.Field, error) {
+	return func() func(string) ([]csv.Field, error) {
+		return csv.NewSplitter(d).SplitFields
+	}
+}
+
+var csvFormat = recordFormat{
+	decompress:    sniffDecompress,
+	header:        true,
+	dialect:       csv.MySQL,
+	continuations: true,
+	fields:        csv.MySQL.SplitFields,
+	newFields:     splitterFields(csv.MySQL),
+}
+
+// ndjsonFormat maps each JSON object to the fields the CSV dump would have
+// for it, so the same extractors apply. JSON null, as well as a missing
+// key, becomes NULL.
+func ndjsonFormat(columns []string) recordFormat {
+	return recordFormat{
+		decompress: sniffDecompress,
+		fields: func(record string) ([]csv.Field, error) {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(record), &object); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal: %w", err)
+			}
+
+			fields := make([]csv.Field, len(columns))
+			for i, column := range columns {
+				raw, ok := object[column]
+				if !ok || bytes.Equal(raw, []byte("null")) {
+					fields[i] = csv.Field{Null: true}
+					continue
+				}
+
+				var value any
+				decoder := json.NewDecoder(bytes.NewReader(raw))
+				decoder.UseNumber()
+				if err := decoder.Decode(&value); err != nil {
+					return nil, fmt.Errorf("%s: %w", column, err)
+				}
+
+				switch value := value.(type) {
+				case string:
+					fields[i] = csv.Field{Value: value}
+				case json.Number:
+					fields[i] = csv.Field{Value: value.String()}
+				default:
+					return nil, fmt.Errorf("%s: unsupported value %s", column, raw)
+				}
+			}
+
+			return fields, nil
+		},
+	}
+}
+
+func (f Format) recordFormat(columns []string) recordFormat {
+	if f == FormatNDJSON {
+		return ndjsonFormat(columns)
+	}
+
+	format := csvFormat
+	format.columns = columns
+
+	return format
+}
+
+// sniffDecompress detects gzip and bzip2 streams from their magic bytes,
+// and passes anything else through untouched.
+func sniffDecompress(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(3)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return errReader{fmt.Errorf("gzip.NewReader: %w", err)}
+		}
+		return gr
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br)
+	default:
+		return br
+	}
+}
+
+// errReader fails every read with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
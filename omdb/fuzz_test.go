@@ -0,0 +1,51 @@
+package omdb
+
+import (
+	"compress/bzip2"
+	"github.com/lsmoura/omdb-api/csv"
+	"io"
+	"os"
+	"testing"
+)
+
+func FuzzExtractors(f *testing.F) {
+	file, err := os.Open("testdata/all_movies.csv.bz2")
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(bzip2.NewReader(file))
+	for i := 0; i < 50; i++ {
+		record, err := r.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(record)
+	}
+	f.Add(`500,"Sequel, The",11,"1977-05-25"`)
+	f.Add(`9,"\N",\N,"\N"`)
+	f.Add(`imdbmovie,tt0076759,11,en`)
+	f.Add(`imdbmovie,tt0076759,\N,\N`)
+	f.Add(`x,"",,`)
+
+	f.Fuzz(func(t *testing.T, record string) {
+		fields, err := csv.MySQL.SplitFields(record)
+		if err != nil {
+			return
+		}
+
+		for _, d := range Datasets {
+			args, err := d.extractor(fields)
+			if err != nil {
+				continue
+			}
+			if len(args) != len(d.Columns) {
+				t.Fatalf("%s: %d values for %d columns", d.Name, len(args), len(d.Columns))
+			}
+		}
+	})
+}
@@ -1,14 +1,12 @@
 package omdb
 
 import (
-	"bufio"
-	"compress/bzip2"
 	"context"
 	"database/sql"
 	"fmt"
 	"github.com/lsmoura/omdb-api/csv"
 	"github.com/lsmoura/omdb-api/logging"
-	"net/http"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -18,17 +16,10 @@ const (
 	MovieLinksURL = "http://www.omdb.org/data/movie_links.csv.bz2"
 )
 
-func scanCSVLine(fileScanner *bufio.Scanner) (string, error) {
-	text := fileScanner.Text()
-	for len(text) > 0 && (text[len(text)-1] == '\\') {
-		if !fileScanner.Scan() {
-			return "", fmt.Errorf("unexpected EOF")
-		}
-		text = text[:len(text)-1] + "\n" + fileScanner.Text()
-	}
-
-	return text, nil
-}
+var (
+	allMoviesColumns  = []string{"id", "name", "parent_id", "date"}
+	movieLinksColumns = []string{"source", "key", "movie_id", "language_iso_639_1"}
+)
 
 func tx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 	tx, err := db.Begin()
@@ -48,58 +39,88 @@ func tx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 	return nil
 }
 
-func allMoviesFieldsToArgs(fields []string) ([]any, error) {
-	if len(fields) != 4 {
-		return nil, fmt.Errorf("invalid number of fields: %d", len(fields))
-	}
+// allMoviesRow is a record of the all_movies dump.
+type allMoviesRow struct {
+	ID       int            `csv:"id"`
+	Name     string         `csv:"name"`
+	ParentID sql.NullInt64  `csv:"parent_id"`
+	Date     sql.NullString `csv:"date"`
+}
 
-	id, err := strconv.Atoi(fields[0])
-	if err != nil {
-		return nil, fmt.Errorf("strconv.Atoi: %w", err)
+func allMoviesFieldsToArgs(fields []csv.Field) ([]any, error) {
+	var row allMoviesRow
+	if err := csv.UnmarshalFields(allMoviesColumns, fields, &row); err != nil {
+		return nil, fmt.Errorf("csv.UnmarshalFields: %w", err)
 	}
-
-	var parentID sql.NullInt64
-	if fields[2] != "" && fields[2] != "\\N" {
-		parentID.Int64, err = strconv.ParseInt(fields[2], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("strconv.ParseInt: %w", err)
-		}
-		parentID.Valid = true
+	// an empty date is no date either
+	if row.Date.String == "" {
+		row.Date.Valid = false
 	}
 
 	return []any{
-		id,
-		fields[1],
-		parentID,
-		fields[3],
+		row.ID,
+		row.Name,
+		row.ParentID,
+		row.Date,
 	}, nil
 }
 
-func ImportAllMovies(ctx context.Context, db *sql.DB) error {
-	// download all movies from omdb
-	req, err := http.NewRequestWithContext(ctx, "GET", AllMoviesURL, nil)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest: %w", err)
-	}
+func ImportAllMovies(ctx context.Context, db *sql.DB, opts ...ImportOption) error {
+	options := newImportOptions(opts)
+	logger := logging.LoggerFromContext(ctx)
 
-	resp, err := http.DefaultClient.Do(req)
+	// download all movies from omdb, unless given another input
+	input, size, err := options.open(ctx, AllMoviesURL)
 	if err != nil {
-		return fmt.Errorf("http.DefaultClient.Do: %w", err)
+		return fmt.Errorf("open: %w", err)
 	}
-	defer resp.Body.Close()
+	defer input.Close()
+
+	const sqlPrefix = "INSERT INTO movies (id, name, parent_id, date) VALUES"
+	const sqlSuffix = " ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, parent_id = EXCLUDED.parent_id, date = EXCLUDED.date, deleted_at = NULL"
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status: %d", resp.StatusCode)
+	// keep track of every id in the dump, so we can find the ones that are gone
+	var seen []int64
+	onRow := func(args []any) {
+		seen = append(seen, int64(args[0].(int)))
 	}
 
-	const sqlPrefix = "INSERT INTO movies (id, name, parent_id, date) VALUES"
-	const sqlSuffix = " ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, parent_id = EXCLUDED.parent_id, date = EXCLUDED.date"
+	finishFn := func(tx *sql.Tx, stats importStats) error {
+		// a rejected row may well be a movie that still exists upstream
+		if stats.rejected > 0 && options.missingPolicy != MissingKeep {
+			if logger != nil {
+				logger.Warn("ImportAllMovies: rows were rejected, skipping missing policy", "rejected", stats.rejected, "policy", options.missingPolicy)
+			}
+			return nil
+		}
 
-	// parse and insert movies in the database
-	bz2Reader := bzip2.NewReader(resp.Body)
-	lineScanner := bufio.NewScanner(bz2Reader)
+		affected, err := applyMissingPolicy(ctx, tx, options.missingPolicy, seen)
+		if err != nil {
+			return fmt.Errorf("applyMissingPolicy: %w", err)
+		}
 
-	err = injectCSV(ctx, db, lineScanner, sqlPrefix, sqlSuffix, nil, allMoviesFieldsToArgs)
+		if logger != nil {
+			logger.Info("ImportAllMovies: missing", "seen", len(seen), "missing", affected, "policy", options.missingPolicy)
+		}
+
+		return nil
+	}
+
+	// parse and insert movies in the database
+	stats, err := injectCSV(ctx, db, input, size, csvImport{
+		dataset:    "all_movies",
+		columns:    allMoviesColumns,
+		countQuery: "SELECT count(*) FROM movies WHERE deleted_at IS NULL",
+		sqlPrefix:  sqlPrefix,
+		sqlSuffix:  sqlSuffix,
+		finishFn:   finishFn,
+		extractor:  allMoviesFieldsToArgs,
+		onRow:      onRow,
+	}, options)
+
+	if logger != nil {
+		logger.Info("ImportAllMovies", "records", stats.lines, "rejected", stats.rejected)
+	}
 
 	if err != nil {
 		return fmt.Errorf("injectCSV: %w", err)
@@ -108,84 +129,178 @@ func ImportAllMovies(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
-func movieLinksFieldsToArgs(fields []string) ([]any, error) {
-	if len(fields) != 4 {
-		return nil, fmt.Errorf("invalid number of fields: %d", len(fields))
-	}
+// movieLinksRow is a record of the movie_links dump.
+type movieLinksRow struct {
+	Source   string         `csv:"source"`
+	Key      string         `csv:"key"`
+	MovieID  sql.NullInt64  `csv:"movie_id"`
+	Language sql.NullString `csv:"language_iso_639_1"`
+}
 
-	var movieID int64
-	if fields[2] != "" && fields[2] != "\\N" {
-		var err error
-		movieID, err = strconv.ParseInt(fields[2], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("strconv.ParseInt: %w", err)
-		}
+func movieLinksFieldsToArgs(fields []csv.Field) ([]any, error) {
+	var row movieLinksRow
+	if err := csv.UnmarshalFields(movieLinksColumns, fields, &row); err != nil {
+		return nil, fmt.Errorf("csv.UnmarshalFields: %w", err)
 	}
 
 	return []any{
-		fields[0], // source
-		fields[1], // key
-		movieID,
-		fields[3], // language
+		row.Source,
+		row.Key,
+		row.MovieID,
+		row.Language,
 	}, nil
 }
 
-func injectCSV(ctx context.Context, db *sql.DB, lineScanner *bufio.Scanner, sqlPrefix, sqlSuffix string, prepareFn func(*sql.Tx) error, extractor func([]string) ([]any, error)) error {
-	var lines int
+// csvImport describes how the rows of one dataset are loaded.
+type csvImport struct {
+	dataset string
+	columns []string
+	// countQuery counts the rows the dump is going to replace, for the
+	// shrink check.
+	countQuery string
+	sqlPrefix  string
+	sqlSuffix  string
+	prepareFn  func(*sql.Tx) error
+	finishFn   func(*sql.Tx, importStats) error
+	// extractor runs concurrently on the parse workers, so it must not
+	// touch shared state. Use onRow for that instead.
+	extractor func([]csv.Field) ([]any, error)
+	// onRow is called by the writer, in file order, for every valid row.
+	onRow func(args []any)
+}
+
+type importStats struct {
+	lines    int
+	rejected int
+}
+
+// injectCSV streams the input through the parsing pipeline and writes the
+// rows in batches within a single transaction.
+func injectCSV(ctx context.Context, db *sql.DB, body io.Reader, size int64, imp csvImport, options importOptions) (importStats, error) {
+	var stats importStats
+
+	runID := options.runID
+	if runID == "" {
+		runID = newRunID()
+	}
+
+	progress := newProgressReporter(imp.dataset, size, options)
+	progress.Start(ctx)
+	defer progress.Stop()
+
+	// reject handles a row that could not be parsed: in strict mode it aborts
+	// the import, in lenient mode it is quarantined until the budget runs out.
+	reject := func(lineNumber int, line string, rowErr error) error {
+		if !options.lenient {
+			return rowErr
+		}
+
+		stats.rejected++
+		progress.rejected.Add(1)
+		if err := recordReject(ctx, db, imp.dataset, lineNumber, line, rowErr); err != nil {
+			return fmt.Errorf("recordReject: %w", err)
+		}
+
+		if options.errorBudget >= 0 && stats.rejected > options.errorBudget {
+			return fmt.Errorf("%w: %d rejected rows, last one at line %d: %s", ErrErrorBudgetExceeded, stats.rejected, lineNumber, rowErr)
+		}
+
+		return nil
+	}
 
 	err := tx(db, func(tx *sql.Tx) error {
-		if prepareFn != nil {
-			if err := prepareFn(tx); err != nil {
+		if err := lockDataset(ctx, tx, imp.dataset, options.waitForLock); err != nil {
+			return fmt.Errorf("lockDataset: %w", err)
+		}
+
+		if err := setImportRun(ctx, tx, runID); err != nil {
+			return fmt.Errorf("setImportRun: %w", err)
+		}
+
+		current, err := currentRows(ctx, tx, imp.countQuery)
+		if err != nil {
+			return fmt.Errorf("currentRows: %w", err)
+		}
+
+		if imp.prepareFn != nil {
+			if err := imp.prepareFn(tx); err != nil {
 				return fmt.Errorf("prepareFn: %w", err)
 			}
 		}
 
-		// test and skip first line
-		if !lineScanner.Scan() {
-			return fmt.Errorf("lineScanner.Scan: %w", lineScanner.Err())
+		hashed := newHashingReader(progress.Reader(body))
+		p := startPipeline(ctx, hashed, options.recordFormat(imp.columns), imp.extractor, options.workers)
+		if err := writeRows(ctx, tx, p, imp, &stats, progress, reject); err != nil {
+			p.Close()
+			return err
+		}
+		if err := p.Close(); err != nil {
+			return fmt.Errorf("pipeline: %w", err)
 		}
 
-		var count int
-
-		var sqlBuf strings.Builder
-		var args []any
+		if err := options.integrity.verify(stats.lines, current, hashed.Sum()); err != nil {
+			return err
+		}
 
-		for lineScanner.Scan() {
-			line, err := scanCSVLine(lineScanner)
-			if err != nil {
-				return fmt.Errorf("scanCSVLine: %w", err)
-			}
-			if len(line) == 0 {
-				continue
+		if imp.finishFn != nil {
+			if err := imp.finishFn(tx, stats); err != nil {
+				return fmt.Errorf("finishFn: %w", err)
 			}
+		}
 
-			elements, err := csv.LineSplit(line)
-			if err != nil {
-				return fmt.Errorf("csv.LineSplit: %w", err)
-			}
+		return nil
+	})
+
+	if err != nil {
+		return stats, fmt.Errorf("tx: %w", err)
+	}
+
+	return stats, nil
+}
 
-			lines++
+// writeRows is the last stage of the pipeline: it takes parsed batches in
+// file order and inserts them, a few thousand parameters at a time.
+func writeRows(ctx context.Context, tx *sql.Tx, p *pipeline, imp csvImport, stats *importStats, progress *progressReporter, reject func(int, string, error) error) error {
+	var count int
 
-			elementArgs, err := extractor(elements)
-			if err != nil {
-				return fmt.Errorf("extractor: %w", err)
+	var sqlBuf strings.Builder
+	var args []any
+
+	for result := range p.results {
+		rows, err := p.wait(ctx, result)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if row.err != nil {
+				if err := reject(row.line, row.text, row.err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			stats.lines++
+			progress.rows.Add(1)
+			if imp.onRow != nil {
+				imp.onRow(row.args)
 			}
 
 			if count > 0 {
 				sqlBuf.WriteString(", ")
 			}
 
-			pieces := make([]string, len(elementArgs))
-			for i := range elementArgs {
+			pieces := make([]string, len(row.args))
+			for i := range row.args {
 				count++
 				pieces[i] = "$" + strconv.Itoa(count)
 			}
 
 			sqlBuf.WriteString(fmt.Sprintf(" (%s)", strings.Join(pieces, ", ")))
-			args = append(args, elementArgs...)
+			args = append(args, row.args...)
 
 			if count >= 4000 {
-				fullQuery := sqlPrefix + sqlBuf.String() + sqlSuffix
+				fullQuery := imp.sqlPrefix + sqlBuf.String() + imp.sqlSuffix
 				if _, err := tx.ExecContext(ctx, fullQuery, args...); err != nil {
 					return fmt.Errorf("tx.ExecContext: %w", err)
 				}
@@ -194,52 +309,66 @@ func injectCSV(ctx context.Context, db *sql.DB, lineScanner *bufio.Scanner, sqlP
 				args = nil
 			}
 		}
+	}
 
-		if len(args) > 0 {
-			fullQuery := sqlPrefix + sqlBuf.String() + sqlSuffix
-			if _, err := tx.ExecContext(ctx, fullQuery, args...); err != nil {
-				return fmt.Errorf("tx.ExecContext: %w", err)
-			}
+	if len(args) > 0 {
+		fullQuery := imp.sqlPrefix + sqlBuf.String() + imp.sqlSuffix
+		if _, err := tx.ExecContext(ctx, fullQuery, args...); err != nil {
+			return fmt.Errorf("tx.ExecContext: %w", err)
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("tx: %w", err)
 	}
 
 	return nil
 }
 
-func ImportMovieLinks(ctx context.Context, db *sql.DB) error {
-	// download all movies from omdb
-	req, err := http.NewRequestWithContext(ctx, "GET", MovieLinksURL, nil)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest: %w", err)
+// syncMovieLinks makes movie_links match movie_links_import, touching only
+// the links that changed. Rejected rows never reach movie_links_import, so
+// when there are any no link is deleted: a malformed line must not cost the
+// link it held.
+func syncMovieLinks(ctx context.Context, tx *sql.Tx, rejected int) (deleted, inserted int64, err error) {
+	const sameLink = `i.source = l.source AND i.key = l.key AND i.movie_id IS NOT DISTINCT FROM l.movie_id
+		AND i.language_iso_639_1 IS NOT DISTINCT FROM l.language_iso_639_1`
+
+	// temporary tables are never analyzed automatically
+	if _, err := tx.ExecContext(ctx, "ANALYZE movie_links_import;"); err != nil {
+		return 0, 0, fmt.Errorf("tx.ExecContext: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("http.DefaultClient.Do: %w", err)
+	if rejected == 0 {
+		result, err := tx.ExecContext(ctx, "DELETE FROM movie_links l WHERE NOT EXISTS (SELECT 1 FROM movie_links_import i WHERE "+sameLink+")")
+		if err != nil {
+			return 0, 0, fmt.Errorf("tx.ExecContext: %w", err)
+		}
+		deleted, _ = result.RowsAffected()
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status: %d", resp.StatusCode)
+	result, err := tx.ExecContext(ctx, "INSERT INTO movie_links (source, key, movie_id, language_iso_639_1) SELECT DISTINCT source, key, movie_id, language_iso_639_1 FROM movie_links_import i WHERE NOT EXISTS (SELECT 1 FROM movie_links l WHERE "+sameLink+") ON CONFLICT DO NOTHING")
+	if err != nil {
+		return 0, 0, fmt.Errorf("tx.ExecContext: %w", err)
 	}
+	inserted, _ = result.RowsAffected()
 
-	const sqlPrefix = "INSERT INTO movie_links (source, key, movie_id, language_iso_639_1) VALUES"
-	const sqlSuffix = " ON CONFLICT DO NOTHING"
+	return deleted, inserted, nil
+}
 
-	var lines int
+func ImportMovieLinks(ctx context.Context, db *sql.DB, opts ...ImportOption) error {
+	options := newImportOptions(opts)
+	logger := logging.LoggerFromContext(ctx)
 
-	// parse and insert movies in the database
-	bz2Reader := bzip2.NewReader(resp.Body)
-	lineScanner := bufio.NewScanner(bz2Reader)
+	// download all movie links from omdb, unless given another input
+	input, size, err := options.open(ctx, MovieLinksURL)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer input.Close()
 
-	err = injectCSV(ctx, db, lineScanner, sqlPrefix, sqlSuffix, func(tx *sql.Tx) error {
-		if _, err := tx.ExecContext(ctx, "DELETE FROM movie_links;"); err != nil {
+	// the dump is loaded into a staging table and then diffed against
+	// movie_links, so only the links that actually changed are touched
+	const sqlPrefix = "INSERT INTO movie_links_import (source, key, movie_id, language_iso_639_1) VALUES"
+	const sqlSuffix = " ON CONFLICT DO NOTHING"
+
+	prepareFn := func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "CREATE TEMPORARY TABLE movie_links_import ON COMMIT DROP AS SELECT source, key, movie_id, language_iso_639_1 FROM movie_links WITH NO DATA;"); err != nil {
 			return fmt.Errorf("tx.ExecContext: %w", err)
 		}
 
@@ -248,9 +377,41 @@ func ImportMovieLinks(ctx context.Context, db *sql.DB) error {
 		}
 
 		return nil
-	}, movieLinksFieldsToArgs)
+	}
+
+	finishFn := func(tx *sql.Tx, stats importStats) error {
+		// a rejected row may well be a link that still exists upstream
+		if stats.rejected > 0 && logger != nil {
+			logger.Warn("ImportMovieLinks: rows were rejected, keeping links missing from the dump", "rejected", stats.rejected)
+		}
+
+		deleted, inserted, err := syncMovieLinks(ctx, tx, stats.rejected)
+		if err != nil {
+			return fmt.Errorf("syncMovieLinks: %w", err)
+		}
 
-	logging.LoggerFromContext(ctx).Info("ImportMovieLinks", "records", lines)
+		if logger != nil {
+			logger.Info("ImportMovieLinks: synced", "deleted", deleted, "inserted", inserted)
+		}
+
+		return nil
+	}
+
+	// parse and insert movie links in the database
+	stats, err := injectCSV(ctx, db, input, size, csvImport{
+		dataset:    "movie_links",
+		columns:    movieLinksColumns,
+		countQuery: "SELECT count(*) FROM movie_links",
+		sqlPrefix:  sqlPrefix,
+		sqlSuffix:  sqlSuffix,
+		prepareFn:  prepareFn,
+		finishFn:   finishFn,
+		extractor:  movieLinksFieldsToArgs,
+	}, options)
+
+	if logger != nil {
+		logger.Info("ImportMovieLinks", "records", stats.lines, "rejected", stats.rejected)
+	}
 
 	if err != nil {
 		return fmt.Errorf("injectCSV: %w", err)
@@ -0,0 +1,78 @@
+package omdb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestSyncMovieLinksSkipsDeletesOnRejects(t *testing.T) {
+	for _, rejected := range []int{0, 1} {
+		db, d := stubDB(t, nil)
+
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		_, _, err = syncMovieLinks(ctx, tx, rejected)
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+
+		var deletes int
+		for _, query := range d.sent() {
+			if strings.HasPrefix(query, "DELETE") {
+				deletes++
+			}
+		}
+		assert.Equal(t, rejected == 0, deletes == 1, "rejected=%d", rejected)
+	}
+}
+
+func TestSyncMovieLinks(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	sync := func(rejected int, links ...string) {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.ExecContext(ctx, "CREATE TEMPORARY TABLE movie_links_import ON COMMIT DROP AS SELECT source, key, movie_id, language_iso_639_1 FROM movie_links WITH NO DATA")
+		require.NoError(t, err)
+		for _, key := range links {
+			_, err = tx.ExecContext(ctx, "INSERT INTO movie_links_import (source, key, movie_id) VALUES ('imdbmovie', $1, 1)", key)
+			require.NoError(t, err)
+		}
+
+		_, _, err = syncMovieLinks(ctx, tx, rejected)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+	keys := func() []string {
+		rows, err := db.QueryContext(ctx, "SELECT key FROM movie_links ORDER BY key")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var keys []string
+		for rows.Next() {
+			var key string
+			require.NoError(t, rows.Scan(&key))
+			keys = append(keys, key)
+		}
+		require.NoError(t, rows.Err())
+
+		return keys
+	}
+
+	sync(0, "tt1", "tt2")
+	assert.Equal(t, []string{"tt1", "tt2"}, keys())
+
+	// tt2 was on a rejected line, so it is kept
+	sync(1, "tt1", "tt3")
+	assert.Equal(t, []string{"tt1", "tt2", "tt3"}, keys())
+
+	sync(0, "tt1", "tt3")
+	assert.Equal(t, []string{"tt1", "tt3"}, keys())
+}
@@ -0,0 +1,292 @@
+package omdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ColumnType is the narrowest SQL type holding every value of a column.
+type ColumnType string
+
+const (
+	TypeInteger ColumnType = "INTEGER"
+	TypeBigint  ColumnType = "BIGINT"
+	TypeDouble  ColumnType = "DOUBLE PRECISION"
+	TypeDate    ColumnType = "DATE"
+	TypeText    ColumnType = "TEXT"
+)
+
+// ColumnProfile describes the values found in a column of a dump.
+type ColumnProfile struct {
+	Name string
+	// Type is TypeText for a column holding nothing but NULL or empty
+	// values. Empty values are NULL to any other type, so they do not
+	// count against it.
+	Type ColumnType
+	// Nulls counts the NULL values, and Empty the empty ones.
+	Nulls int64
+	Empty int64
+	// MaxLength is the length of the longest value, in characters.
+	MaxLength int
+	// Distinct estimates the number of distinct values, NULL aside, within
+	// a couple of percent.
+	Distinct uint64
+
+	typed    int64
+	integer  bool
+	bigint   bool
+	double   bool
+	date     bool
+	distinct *hyperLogLog
+}
+
+func newColumnProfile(name string) *ColumnProfile {
+	return &ColumnProfile{
+		Name:     name,
+		integer:  true,
+		double:   true,
+		date:     true,
+		distinct: newHyperLogLog(),
+	}
+}
+
+func (c *ColumnProfile) add(field csv.Field) {
+	if field.Null {
+		c.Nulls++
+		return
+	}
+
+	value := field.Value
+	c.distinct.add(value)
+	if n := utf8.RuneCountInString(value); n > c.MaxLength {
+		c.MaxLength = n
+	}
+	if value == "" {
+		c.Empty++
+		return
+	}
+	c.typed++
+
+	if c.integer {
+		if n, err := strconv.ParseInt(value, 10, 64); err != nil {
+			c.integer = false
+		} else if n < math.MinInt32 || n > math.MaxInt32 {
+			c.bigint = true
+		}
+	}
+	if c.double && !c.integer {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			c.double = false
+		}
+	}
+	if c.date {
+		if _, err := time.Parse(csv.DefaultDateLayout, value); err != nil {
+			c.date = false
+		}
+	}
+}
+
+func (c *ColumnProfile) finish() {
+	c.Distinct = c.distinct.count()
+
+	switch {
+	case c.typed == 0:
+		c.Type = TypeText
+	case c.integer && c.bigint:
+		c.Type = TypeBigint
+	case c.integer:
+		c.Type = TypeInteger
+	case c.double:
+		c.Type = TypeDouble
+	case c.date:
+		c.Type = TypeDate
+	default:
+		c.Type = TypeText
+	}
+}
+
+// DumpProfile describes a dump, as found by InspectDump.
+type DumpProfile struct {
+	Rows int64
+	// Malformed counts the records that could not be split, or had another
+	// number of fields than the header. They are left out of the columns.
+	Malformed int64
+	Columns   []*ColumnProfile
+	// Samples are the first rows of the dump.
+	Samples [][]csv.Field
+}
+
+// NullRatio returns the share of the rows where the column is NULL.
+func (p *DumpProfile) NullRatio(c *ColumnProfile) float64 {
+	if p.Rows == 0 {
+		return 0
+	}
+
+	return float64(c.Nulls) / float64(p.Rows)
+}
+
+// InspectDump reads a dump whose first record names its columns, and
+// profiles the values of each column. The dump may be compressed with
+// bzip2 or gzip. Up to samples rows are kept as they are.
+func InspectDump(ctx context.Context, r io.Reader, dialect csv.Dialect, samples int) (*DumpProfile, error) {
+	reader := csv.NewReader(sniffDecompress(r))
+	reader.Dialect = dialect
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reader.Read: %w", err)
+	}
+
+	profile := &DumpProfile{}
+	for _, name := range header {
+		profile.Columns = append(profile.Columns, newColumnProfile(name))
+	}
+
+	for {
+		if profile.Rows%recordsPerBatch == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		fields, err := reader.ReadFields()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			profile.Malformed++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reader.ReadFields: %w", err)
+		}
+		if len(fields) != len(header) {
+			profile.Malformed++
+			continue
+		}
+
+		profile.Rows++
+		for i, field := range fields {
+			profile.Columns[i].add(field)
+		}
+		if len(profile.Samples) < samples {
+			profile.Samples = append(profile.Samples, fields)
+		}
+	}
+
+	for _, c := range profile.Columns {
+		c.finish()
+	}
+
+	return profile, nil
+}
+
+// CreateTable drafts the statement creating a table for the dump. Columns
+// without NULL are NOT NULL, and an id column is taken for the primary key.
+func (p *DumpProfile) CreateTable(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", name)
+	for i, c := range p.Columns {
+		fmt.Fprintf(&b, "\t%s %s", c.Name, c.Type)
+		if c.Nulls == 0 {
+			b.WriteString(" NOT NULL")
+		}
+		if c.Name == "id" && c.Nulls == 0 && c.Type != TypeText {
+			b.WriteString(" PRIMARY KEY")
+		}
+		if i < len(p.Columns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+
+	return b.String()
+}
+
+// Descriptor drafts the entry of the dump in Datasets. Its importer is left
+// to write.
+func (p *DumpProfile) Descriptor(name, url string) string {
+	columns := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		columns[i] = strconv.Quote(c.Name)
+	}
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	fmt.Fprintf(&b, "\tName:    %q,\n", name)
+	fmt.Fprintf(&b, "\tURL:     %q,\n", url)
+	fmt.Fprintf(&b, "\tColumns: []string{%s},\n", strings.Join(columns, ", "))
+	b.WriteString("},\n")
+
+	return b.String()
+}
+
+// hyperLogLog estimates the number of distinct strings added to it in a
+// fixed amount of memory.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+// hyperLogLogPrecision makes 4096 registers, for a 1.6% standard error.
+const hyperLogLogPrecision = 12
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hyperLogLogPrecision)}
+}
+
+func (h *hyperLogLog) add(s string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(s))
+	x := mix64(hash.Sum64())
+
+	index := x >> (64 - hyperLogLogPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) count() uint64 {
+	m := float64(len(h.registers))
+
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// small cardinalities are better estimated by linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// mix64 spreads the bits of an FNV hash, which are poorly distributed for
+// short strings.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
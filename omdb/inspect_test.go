@@ -0,0 +1,101 @@
+package omdb
+
+import (
+	"context"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInspectDump(t *testing.T) {
+	f, err := os.Open("testdata/all_movies.csv.bz2")
+	require.NoError(t, err)
+	defer f.Close()
+
+	profile, err := InspectDump(context.Background(), f, csv.MySQL, 2)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 1200, profile.Rows)
+	assert.Zero(t, profile.Malformed)
+	assert.Equal(t, [][]csv.Field{
+		{{Value: "1"}, {Value: "Movie 1"}, {Null: true}, {Null: true}},
+		{{Value: "2"}, {Value: "Movie 2"}, {Null: true}, {Null: true}},
+	}, profile.Samples)
+
+	want := []struct {
+		name  string
+		typ   ColumnType
+		nulls int64
+	}{
+		{name: "id", typ: TypeInteger},
+		{name: "name", typ: TypeText},
+		{name: "parent_id", typ: TypeInteger, nulls: 1199},
+		{name: "date", typ: TypeDate, nulls: 1198},
+	}
+	require.Len(t, profile.Columns, len(want))
+	for i, w := range want {
+		c := profile.Columns[i]
+		assert.Equal(t, w.name, c.Name)
+		assert.Equal(t, w.typ, c.Type, w.name)
+		assert.Equal(t, w.nulls, c.Nulls, w.name)
+	}
+	assert.InDelta(t, 1200, profile.Columns[0].Distinct, 1200*0.05)
+	assert.InDelta(t, 1199.0/1200, profile.NullRatio(profile.Columns[2]), 1e-9)
+
+	assert.Equal(t, `CREATE TABLE all_movies (
+	id INTEGER NOT NULL PRIMARY KEY,
+	name TEXT NOT NULL,
+	parent_id INTEGER,
+	date DATE
+);
+`, profile.CreateTable("all_movies"))
+
+	assert.Equal(t, `{
+	Name:    "all_movies",
+	URL:     "http://www.omdb.org/data/all_movies.csv.bz2",
+	Columns: []string{"id", "name", "parent_id", "date"},
+},
+`, profile.Descriptor("all_movies", AllMoviesURL))
+}
+
+func TestInspectDumpTypes(t *testing.T) {
+	input := "big,score,label,blank,mixed\n" +
+		"1,1,x,,2001-01-01\n" +
+		"9999999999,2.5,\"é\",,\n" +
+		"3,\"oops\n" +
+		"4,-1e3,\\N,\\N,x\n"
+
+	profile, err := InspectDump(context.Background(), strings.NewReader(input), csv.MySQL, 0)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 3, profile.Rows)
+	assert.EqualValues(t, 1, profile.Malformed)
+	assert.Empty(t, profile.Samples)
+
+	types := make([]ColumnType, len(profile.Columns))
+	for i, c := range profile.Columns {
+		types[i] = c.Type
+	}
+	assert.Equal(t, []ColumnType{TypeBigint, TypeDouble, TypeText, TypeText, TypeText}, types)
+
+	label := profile.Columns[2]
+	assert.Equal(t, 1, label.MaxLength)
+	assert.EqualValues(t, 1, label.Nulls)
+	assert.EqualValues(t, 2, profile.Columns[3].Empty)
+	assert.EqualValues(t, 1, profile.Columns[4].Empty)
+}
+
+func TestHyperLogLog(t *testing.T) {
+	h := newHyperLogLog()
+	for i := 0; i < 100000; i++ {
+		h.add(strconv.Itoa(i))
+		h.add(strconv.Itoa(i))
+	}
+
+	assert.InDelta(t, 100000, h.count(), 100000*0.05)
+	assert.Zero(t, newHyperLogLog().count())
+}
@@ -0,0 +1,93 @@
+package omdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrIntegrityCheck is returned when a dump fails one of the sanity checks
+// and the import was rolled back.
+var ErrIntegrityCheck = errors.New("integrity check failed")
+
+// IntegrityChecks are verified after a dump was parsed and before anything
+// is committed.
+type IntegrityChecks struct {
+	// MinRows is the minimum number of valid rows the dump must have.
+	MinRows int
+	// MaxShrinkPercent is how much smaller than the current table the dump
+	// may be. A negative value disables the check.
+	MaxShrinkPercent float64
+	// SHA256 pins the hex encoded checksum of the compressed dump. Empty
+	// disables the check.
+	SHA256 string
+}
+
+// DefaultIntegrityChecks refuse empty dumps and dumps that would drop more
+// than half of a table.
+var DefaultIntegrityChecks = IntegrityChecks{
+	MinRows:          1,
+	MaxShrinkPercent: 50,
+}
+
+// currentRows counts what the table holds before the import touches it.
+func currentRows(ctx context.Context, tx *sql.Tx, countQuery string) (int, error) {
+	if countQuery == "" {
+		return 0, nil
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, countQuery).Scan(&count); err != nil {
+		return 0, fmt.Errorf("tx.QueryRowContext: %w", err)
+	}
+
+	return count, nil
+}
+
+// verify compares an import against the checks. checksum is the hex encoded
+// SHA-256 of the compressed dump.
+func (c IntegrityChecks) verify(rows, current int, checksum string) error {
+	if rows < c.MinRows {
+		return fmt.Errorf("%w: %d rows, expected at least %d", ErrIntegrityCheck, rows, c.MinRows)
+	}
+
+	if c.MaxShrinkPercent >= 0 && current > 0 {
+		shrink := float64(current-rows) / float64(current) * 100
+		if shrink > c.MaxShrinkPercent {
+			return fmt.Errorf("%w: %d rows would replace %d, a %.1f%% shrink (max %.1f%%)", ErrIntegrityCheck, rows, current, shrink, c.MaxShrinkPercent)
+		}
+	}
+
+	if c.SHA256 != "" && !strings.EqualFold(c.SHA256, checksum) {
+		return fmt.Errorf("%w: sha256 is %s, expected %s", ErrIntegrityCheck, checksum, c.SHA256)
+	}
+
+	return nil
+}
+
+// hashingReader computes the SHA-256 of everything read through it.
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.h.Write(p[:n])
+
+	return n, err
+}
+
+func (h *hashingReader) Sum() string {
+	return hex.EncodeToString(h.h.Sum(nil))
+}
@@ -0,0 +1,47 @@
+package omdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIntegrityChecksVerify(t *testing.T) {
+	const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	tests := []struct {
+		name    string
+		checks  IntegrityChecks
+		rows    int
+		current int
+		sum     string
+		wantErr bool
+	}{
+		{name: "empty dump", checks: DefaultIntegrityChecks, rows: 0, current: 0, wantErr: true},
+		{name: "first import", checks: DefaultIntegrityChecks, rows: 10, current: 0},
+		{name: "small shrink", checks: DefaultIntegrityChecks, rows: 60, current: 100},
+		{name: "large shrink", checks: DefaultIntegrityChecks, rows: 40, current: 100, wantErr: true},
+		{name: "shrink disabled", checks: IntegrityChecks{MaxShrinkPercent: -1}, rows: 1, current: 100},
+		{name: "checksum match", checks: IntegrityChecks{SHA256: strings.ToUpper(emptySHA256)}, sum: emptySHA256},
+		{name: "checksum mismatch", checks: IntegrityChecks{SHA256: emptySHA256}, sum: "00", wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.checks.verify(test.rows, test.current, test.sum)
+		if test.wantErr {
+			assert.ErrorIs(t, err, ErrIntegrityCheck, test.name)
+		} else {
+			assert.NoError(t, err, test.name)
+		}
+	}
+}
+
+func TestHashingReader(t *testing.T) {
+	r := newHashingReader(strings.NewReader("hello"))
+	_, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", r.Sum())
+}
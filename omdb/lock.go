@@ -0,0 +1,44 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// ErrImportRunning is returned when another import of the same dataset
+// holds the lock and the import was not asked to wait for it.
+var ErrImportRunning = errors.New("import already running")
+
+// lockKey maps a dataset name to the key of its advisory lock.
+func lockKey(dataset string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("omdb-import:" + dataset))
+
+	return int64(h.Sum64())
+}
+
+// lockDataset takes the advisory lock of dataset for the rest of tx. It is
+// released by postgres when the transaction commits or rolls back.
+func lockDataset(ctx context.Context, tx *sql.Tx, dataset string, wait bool) error {
+	if wait {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockKey(dataset)); err != nil {
+			return fmt.Errorf("pg_advisory_xact_lock: %w", err)
+		}
+
+		return nil
+	}
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", lockKey(dataset)).Scan(&locked); err != nil {
+		return fmt.Errorf("pg_try_advisory_xact_lock: %w", err)
+	}
+
+	if !locked {
+		return fmt.Errorf("%s: %w", dataset, ErrImportRunning)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package omdb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLockKey(t *testing.T) {
+	assert.Equal(t, lockKey("all_movies"), lockKey("all_movies"))
+	assert.NotEqual(t, lockKey("all_movies"), lockKey("movie_links"))
+}
+
+func TestLockDatasetFailsFast(t *testing.T) {
+	// postgres answers false when another session holds the lock
+	db, d := stubDB(t, false)
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	err = lockDataset(ctx, tx, "movie_links", false)
+	assert.ErrorIs(t, err, ErrImportRunning)
+	assert.Contains(t, err.Error(), "movie_links")
+
+	assert.Equal(t, []string{"SELECT pg_try_advisory_xact_lock($1)"}, d.sent())
+}
+
+func TestLockDatasetWaits(t *testing.T) {
+	db := testDB(t)
+
+	ctx := context.Background()
+	holder, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer holder.Rollback()
+	require.NoError(t, lockDataset(ctx, holder, "movie_links", false))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	assert.ErrorIs(t, lockDataset(ctx, tx, "movie_links", false), ErrImportRunning)
+	// another dataset has its own lock
+	tx2, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx2.Rollback()
+	require.NoError(t, lockDataset(ctx, tx2, "all_movies", false))
+
+	waiter, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer waiter.Rollback()
+
+	done := make(chan error)
+	go func() {
+		done <- lockDataset(ctx, waiter, "movie_links", true)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("lockDataset returned while the lock was held: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, holder.Rollback())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lockDataset still waiting after the lock was released")
+	}
+}
@@ -0,0 +1,74 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// MissingPolicy decides what ImportAllMovies does with movies that exist in
+// the database but were not in the latest dump.
+type MissingPolicy string
+
+const (
+	// MissingKeep leaves missing movies untouched.
+	MissingKeep MissingPolicy = "keep"
+	// MissingSoftDelete stamps missing movies with deleted_at, which hides
+	// them from the database package lookups.
+	MissingSoftDelete MissingPolicy = "soft-delete"
+	// MissingHardDelete removes missing movies along with their links.
+	MissingHardDelete MissingPolicy = "hard-delete"
+)
+
+func ParseMissingPolicy(s string) (MissingPolicy, error) {
+	switch p := MissingPolicy(s); p {
+	case MissingKeep, MissingSoftDelete, MissingHardDelete:
+		return p, nil
+	case "":
+		return MissingKeep, nil
+	default:
+		return "", fmt.Errorf("unknown missing policy: %q", s)
+	}
+}
+
+// applyMissingPolicy handles every movie whose ID is not in seen. It must run
+// in the same transaction as the import so a failed run never deletes
+// anything.
+func applyMissingPolicy(ctx context.Context, tx *sql.Tx, policy MissingPolicy, seen []int64) (int64, error) {
+	const missing = "SELECT id FROM movies WHERE id NOT IN (SELECT unnest($1::bigint[]))"
+
+	var queries []string
+	switch policy {
+	case MissingKeep:
+		return 0, nil
+	case MissingSoftDelete:
+		queries = []string{
+			"UPDATE movies SET deleted_at = now() WHERE deleted_at IS NULL AND id IN (" + missing + ")",
+		}
+	case MissingHardDelete:
+		queries = []string{
+			"DELETE FROM movie_links WHERE movie_id IN (" + missing + ")",
+			"UPDATE movies SET parent_id = NULL WHERE parent_id IN (" + missing + ")",
+			"DELETE FROM movies WHERE id IN (" + missing + ")",
+		}
+	default:
+		return 0, fmt.Errorf("unknown missing policy: %q", policy)
+	}
+
+	var affected int64
+	for _, query := range queries {
+		result, err := tx.ExecContext(ctx, query, pq.Array(seen))
+		if err != nil {
+			return 0, fmt.Errorf("tx.ExecContext: %w", err)
+		}
+
+		// only the last statement touches the movies themselves
+		affected, err = result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("result.RowsAffected: %w", err)
+		}
+	}
+
+	return affected, nil
+}
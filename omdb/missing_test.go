@@ -0,0 +1,112 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseMissingPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want MissingPolicy
+	}{
+		{in: "", want: MissingKeep},
+		{in: "keep", want: MissingKeep},
+		{in: "soft-delete", want: MissingSoftDelete},
+		{in: "hard-delete", want: MissingHardDelete},
+	}
+
+	for _, test := range tests {
+		got, err := ParseMissingPolicy(test.in)
+
+		require.NoError(t, err, "ParseMissingPolicy(%q)", test.in)
+		assert.Equal(t, test.want, got)
+	}
+
+	_, err := ParseMissingPolicy("delete")
+	assert.Error(t, err)
+}
+
+func TestApplyMissingPolicyKeep(t *testing.T) {
+	// keeping every movie never touches the transaction
+	affected, err := applyMissingPolicy(context.Background(), nil, MissingKeep, nil)
+	require.NoError(t, err)
+	assert.Zero(t, affected)
+
+	_, err = applyMissingPolicy(context.Background(), nil, MissingPolicy("delete"), nil)
+	assert.Error(t, err)
+}
+
+func TestApplyMissingPolicy(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	seed := func() {
+		for _, stmt := range []string{
+			"TRUNCATE movies, movie_links",
+			"INSERT INTO movies (id, name, parent_id) VALUES (1, 'kept', NULL), (2, 'gone', NULL), (3, 'child', 2), (4, 'deleted', NULL)",
+			"UPDATE movies SET deleted_at = now() - interval '1 day' WHERE id = 4",
+			"INSERT INTO movie_links (source, key, movie_id) VALUES ('imdbmovie', 'tt1', 1), ('imdbmovie', 'tt2', 2)",
+		} {
+			_, err := db.ExecContext(ctx, stmt)
+			require.NoError(t, err)
+		}
+	}
+	seen := []int64{1, 3}
+
+	apply := func(policy MissingPolicy) int64 {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		affected, err := applyMissingPolicy(ctx, tx, policy, seen)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		return affected
+	}
+
+	t.Run("soft-delete", func(t *testing.T) {
+		seed()
+
+		// movie 4 was already deleted, and keeps its deletion time
+		assert.Equal(t, int64(1), apply(MissingSoftDelete))
+
+		var deleted []int64
+		rows, err := db.QueryContext(ctx, "SELECT id FROM movies WHERE deleted_at IS NOT NULL ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			require.NoError(t, rows.Scan(&id))
+			deleted = append(deleted, id)
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []int64{2, 4}, deleted)
+
+		var links int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM movie_links").Scan(&links))
+		assert.Equal(t, 2, links)
+	})
+
+	t.Run("hard-delete", func(t *testing.T) {
+		seed()
+
+		assert.Equal(t, int64(2), apply(MissingHardDelete))
+
+		var movies int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM movies").Scan(&movies))
+		assert.Equal(t, 2, movies)
+
+		var parentID sql.NullInt64
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT parent_id FROM movies WHERE id = 3").Scan(&parentID))
+		assert.False(t, parentID.Valid)
+
+		var links int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM movie_links WHERE movie_id = 2").Scan(&links))
+		assert.Zero(t, links)
+	})
+}
@@ -0,0 +1,102 @@
+package omdb
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is reported for a text field that is not valid UTF-8 when
+// the import normalizes with NormalizeReject.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// Normalization decides what the import does with text fields that are not
+// valid UTF-8, or not in Unicode normalization form C. Both break name
+// searches, and invalid UTF-8 also breaks JSON output.
+type Normalization string
+
+const (
+	// NormalizeOff stores text fields as they are in the dump.
+	NormalizeOff Normalization = "off"
+	// NormalizeRepair decodes bytes that are not valid UTF-8 as Windows-1252,
+	// the Latin-1 superset older dumps were written in, and normalizes text
+	// to NFC.
+	NormalizeRepair Normalization = "repair"
+	// NormalizeReject rejects rows with a field that is not valid UTF-8, and
+	// normalizes the others to NFC.
+	NormalizeReject Normalization = "reject"
+)
+
+func ParseNormalization(s string) (Normalization, error) {
+	switch n := Normalization(s); n {
+	case NormalizeOff, NormalizeRepair, NormalizeReject:
+		return n, nil
+	case "":
+		return NormalizeOff, nil
+	default:
+		return "", fmt.Errorf("unknown normalization: %q", s)
+	}
+}
+
+// normalizer returns a function normalizing the fields of a record in
+// place, or nil when there is nothing to do.
+func (n Normalization) normalizer() func([]csv.Field) error {
+	if n == NormalizeOff || n == "" {
+		return nil
+	}
+
+	return func(fields []csv.Field) error {
+		for i, field := range fields {
+			if field.Null {
+				continue
+			}
+
+			value, err := normalizeText(field.Value, n == NormalizeRepair)
+			if err != nil {
+				return &csv.DecodeError{Field: i, Err: err}
+			}
+			fields[i].Value = value
+		}
+
+		return nil
+	}
+}
+
+// normalizeText returns s in NFC, repairing invalid UTF-8 if asked to.
+// Text that is already valid and normalized, like any ASCII text, is
+// returned as is.
+func normalizeText(s string, repair bool) (string, error) {
+	if utf8.ValidString(s) {
+		if norm.NFC.IsNormalString(s) {
+			return s, nil
+		}
+		return norm.NFC.String(s), nil
+	}
+
+	if !repair {
+		for i := 0; i < len(s); {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				return "", fmt.Errorf("%w at byte %d", ErrInvalidUTF8, i)
+			}
+			i += size
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + len(s)/2)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			r = charmap.Windows1252.DecodeByte(s[i])
+		}
+		b.WriteRune(r)
+		i += size
+	}
+
+	return norm.NFC.String(b.String()), nil
+}
@@ -0,0 +1,86 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseNormalization(t *testing.T) {
+	for in, want := range map[string]Normalization{"": NormalizeOff, "off": NormalizeOff, "repair": NormalizeRepair, "reject": NormalizeReject} {
+		got, err := ParseNormalization(in)
+		require.NoError(t, err, "ParseNormalization(%q)", in)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseNormalization("nfc")
+	assert.Error(t, err)
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		in     string
+		repair string
+		reject string
+	}{
+		{in: "Star Wars", repair: "Star Wars", reject: "Star Wars"},
+		{in: "Ame\u0301lie", repair: "Amélie", reject: "Amélie"},
+		{in: "Am\xe9lie", repair: "Amélie"},
+		{in: "\x93Quoted\x94 Ame\u0301lie", repair: "“Quoted” Amélie"},
+	}
+
+	for _, test := range tests {
+		got, err := normalizeText(test.in, true)
+		require.NoError(t, err, "%q", test.in)
+		assert.Equal(t, test.repair, got, "%q", test.in)
+
+		got, err = normalizeText(test.in, false)
+		if test.reject == "" {
+			assert.ErrorIs(t, err, ErrInvalidUTF8, "%q", test.in)
+			continue
+		}
+		require.NoError(t, err, "%q", test.in)
+		assert.Equal(t, test.reject, got, "%q", test.in)
+	}
+}
+
+func TestPipelineNormalization(t *testing.T) {
+	input := "id,name,parent_id,date\n1,\"Am\xe9lie\",\\N,\\N\n2,\"Ame\u0301lie\",\\N,\\N\n"
+
+	parse := func(n Normalization) []parsedRow {
+		format := newImportOptions([]ImportOption{WithNormalization(n)}).recordFormat(allMoviesColumns)
+		format.decompress = nil
+
+		p := startPipeline(context.Background(), strings.NewReader(input), format, allMoviesFieldsToArgs, 2)
+		var rows []parsedRow
+		for result := range p.results {
+			rows = append(rows, <-result...)
+		}
+		require.NoError(t, p.Close())
+		require.Len(t, rows, 2)
+
+		return rows
+	}
+
+	rows := parse(NormalizeOff)
+	assert.Equal(t, "Am\xe9lie", rows[0].args[1])
+	assert.Equal(t, "Ame\u0301lie", rows[1].args[1])
+
+	rows = parse(NormalizeRepair)
+	for _, row := range rows {
+		require.NoError(t, row.err)
+		assert.Equal(t, []any{row.args[0], "Amélie", sql.NullInt64{}, sql.NullString{}}, row.args)
+	}
+
+	rows = parse(NormalizeReject)
+	var de *csv.DecodeError
+	require.ErrorAs(t, rows[0].err, &de)
+	assert.Equal(t, 1, de.Field)
+	assert.ErrorIs(t, rows[0].err, ErrInvalidUTF8)
+	require.NoError(t, rows[1].err)
+	assert.Equal(t, "Amélie", rows[1].args[1])
+}
@@ -0,0 +1,208 @@
+package omdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+type importOptions struct {
+	missingPolicy MissingPolicy
+	lenient       bool
+	errorBudget   int
+	workers       int
+	waitForLock   bool
+	fetcher       *Fetcher
+	input         func(ctx context.Context) (io.ReadCloser, int64, error)
+	format        Format
+	integrity     IntegrityChecks
+	runID         string
+	strictHeader  bool
+	dialect       csv.Dialect
+	normalization Normalization
+
+	progress         ProgressFunc
+	progressInterval time.Duration
+}
+
+// ImportOption configures a single import run.
+type ImportOption func(*importOptions)
+
+func newImportOptions(opts []ImportOption) importOptions {
+	o := importOptions{
+		missingPolicy: MissingKeep,
+		workers:       runtime.GOMAXPROCS(0),
+		fetcher:       NewFetcher(),
+		format:        FormatCSV,
+		dialect:       csv.MySQL,
+		normalization: NormalizeOff,
+		integrity:     DefaultIntegrityChecks,
+
+		progressInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMissingPolicy sets what happens to rows that are in the database but
+// were not present in the downloaded dump.
+func WithMissingPolicy(policy MissingPolicy) ImportOption {
+	return func(o *importOptions) {
+		o.missingPolicy = policy
+	}
+}
+
+// WithLenient makes the import quarantine malformed rows in the
+// import_rejects table instead of aborting. Once more than errorBudget rows
+// have been rejected the import is aborted anyway; a negative budget never
+// aborts.
+func WithLenient(errorBudget int) ImportOption {
+	return func(o *importOptions) {
+		o.lenient = true
+		o.errorBudget = errorBudget
+	}
+}
+
+// WithWorkers sets how many goroutines parse rows concurrently. It defaults
+// to GOMAXPROCS.
+func WithWorkers(n int) ImportOption {
+	return func(o *importOptions) {
+		o.workers = n
+	}
+}
+
+// WithProgress calls fn with a progress snapshot every interval while the
+// import runs, and once more when it ends.
+func WithProgress(fn ProgressFunc, interval time.Duration) ImportOption {
+	return func(o *importOptions) {
+		o.progress = fn
+		if interval > 0 {
+			o.progressInterval = interval
+		}
+	}
+}
+
+// WithWaitForLock makes the import wait for a concurrent import of the same
+// dataset to finish, instead of failing with ErrImportRunning.
+func WithWaitForLock() ImportOption {
+	return func(o *importOptions) {
+		o.waitForLock = true
+	}
+}
+
+// WithFetcher sets the Fetcher used to download the dumps.
+func WithFetcher(f *Fetcher) ImportOption {
+	return func(o *importOptions) {
+		o.fetcher = f
+	}
+}
+
+// WithIntegrityChecks replaces DefaultIntegrityChecks for the import.
+func WithIntegrityChecks(c IntegrityChecks) ImportOption {
+	return func(o *importOptions) {
+		o.integrity = c
+	}
+}
+
+// WithInput imports from open instead of downloading the dump from omdb.
+// open returns the input and its size, or -1 if unknown.
+func WithInput(open func(ctx context.Context) (io.ReadCloser, int64, error)) ImportOption {
+	return func(o *importOptions) {
+		o.input = open
+	}
+}
+
+// WithFormat sets the format of the input, which is FormatCSV by default.
+func WithFormat(format Format) ImportOption {
+	return func(o *importOptions) {
+		o.format = format
+	}
+}
+
+// WithRunID sets the import run the changes made by the import are
+// recorded under in catalog_changes. Every import gets a random one
+// otherwise; ImportAll shares it between its datasets.
+func WithRunID(id string) ImportOption {
+	return func(o *importOptions) {
+		o.runID = id
+	}
+}
+
+// WithDialect sets the dialect of FormatCSV inputs, for mirrors that do not
+// write their dumps the way omdb does.
+func WithDialect(d csv.Dialect) ImportOption {
+	return func(o *importOptions) {
+		o.dialect = d
+	}
+}
+
+// WithStrictHeader makes the import fail when the header of the dump
+// differs from the expected columns in any way. By default reordered or
+// additional columns are mapped by name, and only missing ones fail.
+func WithStrictHeader() ImportOption {
+	return func(o *importOptions) {
+		o.strictHeader = true
+	}
+}
+
+// WithFile imports from a local file instead of downloading the dump.
+func WithFile(path string) ImportOption {
+	return WithInput(func(context.Context) (io.ReadCloser, int64, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("os.Open: %w", err)
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, fmt.Errorf("f.Stat: %w", err)
+		}
+
+		return f, info.Size(), nil
+	})
+}
+
+// open returns the input of the import, downloading url unless another
+// input was given.
+func (o importOptions) open(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	if o.input != nil {
+		return o.input(ctx)
+	}
+
+	download, err := o.fetcher.Fetch(ctx, url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetcher.Fetch: %w", err)
+	}
+
+	return download, download.Size, nil
+}
+
+// WithNormalization sets how text fields are checked and normalized before
+// they are stored. Nothing is done to them by default.
+func WithNormalization(n Normalization) ImportOption {
+	return func(o *importOptions) {
+		o.normalization = n
+	}
+}
+
+// recordFormat returns how to read an input holding the given columns.
+func (o importOptions) recordFormat(columns []string) recordFormat {
+	format := o.format.recordFormat(columns)
+	format.strictHeader = o.strictHeader
+	format.normalize = o.normalization.normalizer()
+	if o.format == FormatCSV {
+		format.dialect = o.dialect
+		format.fields = o.dialect.SplitFields
+		format.newFields = splitterFields(o.dialect)
+	}
+
+	return format
+}
@@ -0,0 +1,408 @@
+package omdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/lsmoura/omdb-api/logging"
+	"io"
+	"sync"
+)
+
+const (
+	// recordsPerBatch is how many records a parse worker handles at a time.
+	recordsPerBatch = 500
+	// readChunkSize is the size of the compressed chunks handed to the
+	// decompressor.
+	readChunkSize = 64 * 1024
+)
+
+type rawRecord struct {
+	line int
+	text string
+}
+
+type parsedRow struct {
+	line int
+	text string
+	args []any
+	err  error
+}
+
+type parseJob struct {
+	records []rawRecord
+	mapping *columnMapping
+	result  chan []parsedRow
+}
+
+// columnMapping rearranges the fields of a record whose header does not
+// list the expected columns in the expected order.
+type columnMapping struct {
+	// index holds, for every expected column, the field it is read from.
+	index []int
+	// width is the number of columns in the header.
+	width int
+}
+
+func (m *columnMapping) apply(fields []csv.Field) ([]csv.Field, error) {
+	if len(fields) != m.width {
+		return nil, fmt.Errorf("%w: expected %d, got %d", csv.ErrFieldCount, m.width, len(fields))
+	}
+
+	mapped := make([]csv.Field, len(m.index))
+	for i, index := range m.index {
+		mapped[i] = fields[index]
+	}
+
+	return mapped, nil
+}
+
+// pipeline streams an input through
+//
+//	reader -> decompressor -> parse workers -> ordered results
+//
+// Every stage is connected by bounded channels, so a slow database writer
+// applies back pressure all the way to the download.
+type pipeline struct {
+	// done is closed once the pipeline is torn down, by a failing stage or
+	// by Close.
+	done   <-chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// results yields one channel per batch, in file order. Each of them
+	// receives exactly one value once a worker parsed the batch.
+	results chan chan []parsedRow
+
+	mu  sync.Mutex
+	err error
+}
+
+func startPipeline(ctx context.Context, body io.Reader, format recordFormat, extractor func([]csv.Field) ([]any, error), workers int) *pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &pipeline{
+		done:    ctx.Done(),
+		cancel:  cancel,
+		results: make(chan chan []parsedRow, workers*2),
+	}
+
+	chunks := make(chan []byte, workers*2)
+	jobs := make(chan parseJob, workers*2)
+
+	// The reader is not part of the wait group: it may be stuck in a Read
+	// on a stalled connection, and only returns once the caller closes the
+	// body or the request context ends.
+	go func() {
+		defer close(chunks)
+
+		// cancellation is reported by the stages downstream
+		if err := readChunks(ctx, body, chunks); err != nil && ctx.Err() == nil {
+			p.fail(fmt.Errorf("readChunks: %w", err))
+		}
+	}()
+
+	p.wg.Add(1 + workers)
+
+	go func() {
+		defer p.wg.Done()
+		defer close(jobs)
+		defer close(p.results)
+
+		compressed := &chunkReader{ctx: ctx, chunks: chunks}
+		var decompressed io.Reader = compressed
+		if format.decompress != nil {
+			decompressed = format.decompress(compressed)
+		}
+		reader := csv.NewReader(decompressed)
+		reader.Dialect = format.dialect
+		reader.Continuations = format.continuations
+		if err := splitRecords(ctx, reader, format, jobs, p.results); err != nil {
+			p.fail(fmt.Errorf("splitRecords: %w", err))
+			return
+		}
+
+		// consume anything after the end of the compressed stream, so the
+		// body is always read to EOF once the pipeline succeeds
+		if _, err := io.Copy(io.Discard, compressed); err != nil {
+			p.fail(fmt.Errorf("io.Copy: %w", err))
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+
+			fields := format.fields
+			if format.newFields != nil {
+				fields = format.newFields()
+			}
+			if format.normalize != nil {
+				fields = normalized(fields, format.normalize)
+			}
+
+			for job := range jobs {
+				job.result <- parseRecords(job.records, fields, job.mapping, extractor)
+			}
+		}()
+	}
+
+	return p
+}
+
+// fail records the first error of any stage and tears the pipeline down.
+func (p *pipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+
+	p.cancel()
+}
+
+// wait returns the rows of a batch taken from results once a worker parsed
+// them. It gives up when ctx ends or the pipeline is torn down, returning
+// the error that tore it down.
+func (p *pipeline) wait(ctx context.Context, result <-chan []parsedRow) ([]parsedRow, error) {
+	select {
+	case rows := <-result:
+		return rows, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+	}
+
+	// the batch may have been parsed before the pipeline went down
+	select {
+	case rows := <-result:
+		return rows, nil
+	default:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return nil, context.Canceled
+}
+
+// Close stops every stage and waits for them to exit. It returns the first
+// error raised by a stage, if any.
+func (p *pipeline) Close() error {
+	p.cancel()
+
+	// unblock the splitter in case the consumer stopped reading early
+	for range p.results {
+	}
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+func readChunks(ctx context.Context, r io.Reader, chunks chan<- []byte) error {
+	for {
+		buf := make([]byte, readChunkSize)
+		n, err := r.Read(buf)
+		if n > 0 {
+			select {
+			case chunks <- buf[:n]:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// chunkReader turns the channel fed by readChunks back into an io.Reader.
+type chunkReader struct {
+	ctx    context.Context
+	chunks <-chan []byte
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		select {
+		case chunk, ok := <-r.chunks:
+			if !ok {
+				if err := r.ctx.Err(); err != nil {
+					return 0, err
+				}
+				return 0, io.EOF
+			}
+			r.buf = chunk
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+// mapHeader checks the header of the input against the expected columns.
+// A header that only reorders them or adds others is adapted to, unless
+// the format wants a strict header, by rearranging every record before it
+// reaches the extractor. It returns nil when no mapping is needed.
+func mapHeader(ctx context.Context, format recordFormat, header []string) (*columnMapping, error) {
+	if format.columns == nil {
+		return nil, nil
+	}
+
+	changed := csv.CheckHeader(format.columns, header)
+	if changed == nil {
+		return nil, nil
+	}
+	if format.strictHeader {
+		return nil, changed
+	}
+
+	index, err := csv.MapColumns(format.columns, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger := logging.LoggerFromContext(ctx); logger != nil {
+		logger.Warn("mapping columns by name", "reason", changed)
+	}
+
+	return &columnMapping{index: index, width: len(header)}, nil
+}
+
+// splitRecords checks the header, groups the remaining records into batches
+// and queues each batch both for the workers and, in order, for the writer.
+func splitRecords(ctx context.Context, reader *csv.Reader, format recordFormat, jobs chan<- parseJob, results chan<- chan []parsedRow) error {
+	var mapping *columnMapping
+	if format.header {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("missing header")
+		}
+		if err != nil {
+			return fmt.Errorf("reader.Read: %w", err)
+		}
+
+		mapping, err = mapHeader(ctx, format, header)
+		if err != nil {
+			return fmt.Errorf("mapHeader: %w", err)
+		}
+	}
+
+	var records []rawRecord
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+
+		job := parseJob{records: records, mapping: mapping, result: make(chan []parsedRow, 1)}
+		records = nil
+
+		// the job goes to the workers first: they parse every job they are
+		// sent, so a result channel handed to the writer is always filled
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case results <- job.result:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		return nil
+	}
+
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reader.ReadRecord: %w", err)
+		}
+
+		records = append(records, rawRecord{line: reader.Line(), text: record})
+		if len(records) >= recordsPerBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// normalized returns a split function running normalize on the fields split
+// returns.
+func normalized(split func(string) ([]csv.Field, error), normalize func([]csv.Field) error) func(string) ([]csv.Field, error) {
+	return func(record string) ([]csv.Field, error) {
+		fields, err := split(record)
+		if err != nil {
+			return nil, err
+		}
+		if err := normalize(fields); err != nil {
+			return nil, err
+		}
+
+		return fields, nil
+	}
+}
+
+func parseRecords(records []rawRecord, fields func(string) ([]csv.Field, error), mapping *columnMapping, extractor func([]csv.Field) ([]any, error)) []parsedRow {
+	rows := make([]parsedRow, len(records))
+	for i, record := range records {
+		rows[i].line = record.line
+		rows[i].text = record.text
+
+		elements, err := fields(record.text)
+		if err != nil {
+			// positions are relative to the record, make them point into
+			// the input instead
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				pe.Line += record.line - 1
+			}
+			rows[i].err = fmt.Errorf("fields: %w", err)
+			continue
+		}
+
+		if mapping != nil {
+			elements, err = mapping.apply(elements)
+			if err != nil {
+				rows[i].err = fmt.Errorf("mapping.apply: %w", err)
+				continue
+			}
+		}
+
+		args, err := extractor(elements)
+		if err != nil {
+			rows[i].err = fmt.Errorf("extractor: %w", err)
+			continue
+		}
+		rows[i].args = args
+	}
+
+	return rows
+}
@@ -0,0 +1,206 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lsmoura/omdb-api/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func collectPipeline(t *testing.T, workers int) []parsedRow {
+	t.Helper()
+
+	f, err := os.Open("testdata/all_movies.csv.bz2")
+	require.NoError(t, err)
+	defer f.Close()
+
+	p := startPipeline(context.Background(), f, csvFormat, allMoviesFieldsToArgs, workers)
+
+	var rows []parsedRow
+	for result := range p.results {
+		rows = append(rows, <-result...)
+	}
+	require.NoError(t, p.Close())
+
+	return rows
+}
+
+func TestPipelineOrdered(t *testing.T) {
+	for _, workers := range []int{1, 4, 16} {
+		rows := collectPipeline(t, workers)
+		require.Len(t, rows, 1200)
+
+		for i, row := range rows {
+			require.NoError(t, row.err)
+			assert.Equal(t, i+1, row.args[0], "workers=%d", workers)
+		}
+
+		// the multi-line record shifts every line number after it
+		assert.Equal(t, 8, rows[6].line)
+		assert.Equal(t, "A title\nspanning lines", rows[6].args[1])
+		assert.Equal(t, 10, rows[7].line)
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	// a body that never yields any data, like a stalled download
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := startPipeline(ctx, r, csvFormat, allMoviesFieldsToArgs, 2)
+	cancel()
+
+	assert.ErrorIs(t, p.Close(), context.Canceled)
+}
+
+func TestParseRecordsPositions(t *testing.T) {
+	rows := parseRecords([]rawRecord{{line: 42, text: "1,\"multi\nline,\\N,\\N"}}, csv.MySQL.SplitFields, nil, allMoviesFieldsToArgs)
+	require.Len(t, rows, 1)
+
+	var pe *csv.ParseError
+	require.ErrorAs(t, rows[0].err, &pe)
+	assert.Equal(t, 42, pe.Line)
+	assert.Equal(t, 3, pe.Column)
+}
+
+func TestParseRecordsNull(t *testing.T) {
+	rows := parseRecords([]rawRecord{
+		{line: 2, text: `1,"Movie 1",\N,\N`},
+		{line: 3, text: `2,"\N",\N,"\N"`},
+	}, csv.MySQL.SplitFields, nil, allMoviesFieldsToArgs)
+	require.Len(t, rows, 2)
+
+	require.NoError(t, rows[0].err)
+	assert.Equal(t, []any{1, "Movie 1", sql.NullInt64{}, sql.NullString{}}, rows[0].args)
+
+	// quoted, \N is text
+	require.NoError(t, rows[1].err)
+	assert.Equal(t, []any{2, `\N`, sql.NullInt64{}, sql.NullString{String: `\N`, Valid: true}}, rows[1].args)
+}
+
+func TestParseRecordsNullLinks(t *testing.T) {
+	rows := parseRecords([]rawRecord{
+		{line: 2, text: `imdbmovie,tt0076759,11,en`},
+		{line: 3, text: `imdbmovie,tt0000001,\N,\N`},
+	}, csv.MySQL.SplitFields, nil, movieLinksFieldsToArgs)
+	require.Len(t, rows, 2)
+
+	require.NoError(t, rows[0].err)
+	assert.Equal(t, []any{"imdbmovie", "tt0076759", sql.NullInt64{Int64: 11, Valid: true}, sql.NullString{String: "en", Valid: true}}, rows[0].args)
+	require.NoError(t, rows[1].err)
+	assert.Equal(t, []any{"imdbmovie", "tt0000001", sql.NullInt64{}, sql.NullString{}}, rows[1].args)
+
+	// an empty date is NULL as well
+	rows = parseRecords([]rawRecord{{line: 2, text: `1,"Movie 1",\N,`}}, csv.MySQL.SplitFields, nil, allMoviesFieldsToArgs)
+	require.NoError(t, rows[0].err)
+	assert.Equal(t, sql.NullString{}, rows[0].args[3])
+}
+
+func TestPipelineHeader(t *testing.T) {
+	format := csvFormat
+	format.decompress = nil
+	format.columns = allMoviesColumns
+
+	parse := func(format recordFormat, input string) ([]parsedRow, error) {
+		p := startPipeline(context.Background(), strings.NewReader(input), format, allMoviesFieldsToArgs, 2)
+
+		var rows []parsedRow
+		for result := range p.results {
+			rows = append(rows, <-result...)
+		}
+
+		return rows, p.Close()
+	}
+
+	// reordered and additional columns are mapped by name
+	rows, err := parse(format, "date,kind,id,parent_id,name\n1977-05-25,movie,11,\\N,\"Star Wars\"\n2000-01-01,movie,12\n")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NoError(t, rows[0].err)
+	assert.Equal(t, []any{11, "Star Wars", sql.NullInt64{}, sql.NullString{String: "1977-05-25", Valid: true}}, rows[0].args)
+	assert.ErrorIs(t, rows[1].err, csv.ErrFieldCount)
+
+	_, err = parse(format, "id,name,date\n11,\"Star Wars\",1977-05-25\n")
+	var he *csv.HeaderError
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, []string{"parent_id"}, he.Missing)
+
+	format.strictHeader = true
+	_, err = parse(format, "id,parent_id,name,date\n11,\\N,\"Star Wars\",1977-05-25\n")
+	assert.ErrorContains(t, err, "header changed (reordered)")
+
+	rows, err = parse(format, "id,name,parent_id,date\n11,\"Star Wars\",\\N,1977-05-25\n")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 11, rows[0].args[0])
+}
+
+func TestPipelineDialect(t *testing.T) {
+	format := newImportOptions([]ImportOption{WithDialect(csv.TSV)}).recordFormat(allMoviesColumns)
+	format.decompress = nil
+
+	input := "id\tname\tparent_id\tdate\n11\tStar Wars, \"Episode IV\"\t\\N\t1977-05-25\n"
+	p := startPipeline(context.Background(), strings.NewReader(input), format, allMoviesFieldsToArgs, 1)
+
+	var rows []parsedRow
+	for result := range p.results {
+		rows = append(rows, <-result...)
+	}
+	require.NoError(t, p.Close())
+
+	require.Len(t, rows, 1)
+	require.NoError(t, rows[0].err)
+	assert.Equal(t, []any{11, `Star Wars, "Episode IV"`, sql.NullInt64{}, sql.NullString{String: "1977-05-25", Valid: true}}, rows[0].args)
+}
+
+// droppedBody yields data once, then fails like a dropped connection.
+type droppedBody struct {
+	data []byte
+}
+
+func (b *droppedBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	return n, nil
+}
+
+func TestParseDumpDroppedBody(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id,name,parent_id,date\n")
+	for i := 1; b.Len() < 4*readChunkSize; i++ {
+		fmt.Fprintf(&b, "%d,\"Movie %d\",\\N,\\N\n", i, i)
+	}
+	dump := []byte(b.String())
+
+	d, ok := LookupDataset("all_movies")
+	require.True(t, ok)
+
+	// the failure races the batches in flight, so give it many chances
+	for i := 0; i < 200; i++ {
+		done := make(chan error, 1)
+		go func() {
+			body := &droppedBody{data: dump[:readChunkSize]}
+			done <- d.ParseDump(context.Background(), body, func([]any) error { return nil }, WithWorkers(4))
+		}()
+
+		select {
+		case err := <-done:
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("run %d: ParseDump hangs after the body failed", i)
+		}
+	}
+}
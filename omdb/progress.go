@@ -0,0 +1,167 @@
+package omdb
+
+import (
+	"context"
+	"github.com/lsmoura/omdb-api/logging"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// progressLogInterval is how often a running import logs its progress.
+const progressLogInterval = 10 * time.Second
+
+// Progress is a snapshot of a running import.
+type Progress struct {
+	Dataset string
+	// BytesRead counts compressed bytes read from the download so far.
+	BytesRead int64
+	// TotalBytes is the Content-Length of the download, or -1 if unknown.
+	TotalBytes    int64
+	Rows          int64
+	Rejected      int64
+	Elapsed       time.Duration
+	RowsPerSecond float64
+	// ETA is estimated from the bytes left to read, and is zero when the
+	// total size is unknown.
+	ETA time.Duration
+	// Done is set on the last snapshot of an import, successful or not.
+	Done bool
+}
+
+// Fraction returns how much of the download was read, between 0 and 1, or
+// -1 if the total size is unknown.
+func (p Progress) Fraction() float64 {
+	if p.TotalBytes <= 0 {
+		return -1
+	}
+
+	return float64(p.BytesRead) / float64(p.TotalBytes)
+}
+
+// ProgressFunc receives progress snapshots. It is called from a separate
+// goroutine and should return quickly.
+type ProgressFunc func(Progress)
+
+type progressReporter struct {
+	dataset  string
+	total    int64
+	start    time.Time
+	callback ProgressFunc
+	interval time.Duration
+
+	bytesRead atomic.Int64
+	rows      atomic.Int64
+	rejected  atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgressReporter(dataset string, total int64, options importOptions) *progressReporter {
+	return &progressReporter{
+		dataset:  dataset,
+		total:    total,
+		start:    time.Now(),
+		callback: options.progress,
+		interval: options.progressInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Reader counts the bytes read through r.
+func (p *progressReporter) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, n: &p.bytesRead}
+}
+
+func (p *progressReporter) snapshot() Progress {
+	progress := Progress{
+		Dataset:    p.dataset,
+		BytesRead:  p.bytesRead.Load(),
+		TotalBytes: p.total,
+		Rows:       p.rows.Load(),
+		Rejected:   p.rejected.Load(),
+		Elapsed:    time.Since(p.start),
+	}
+
+	if seconds := progress.Elapsed.Seconds(); seconds > 0 {
+		progress.RowsPerSecond = float64(progress.Rows) / seconds
+	}
+
+	if fraction := progress.Fraction(); fraction > 0 && fraction < 1 {
+		progress.ETA = time.Duration(float64(progress.Elapsed) * (1 - fraction) / fraction)
+	}
+
+	return progress
+}
+
+// Start emits progress until Stop is called: to the callback every
+// interval, and to the context logger every progressLogInterval.
+func (p *progressReporter) Start(ctx context.Context) {
+	logger := logging.LoggerFromContext(ctx)
+
+	interval := p.interval
+	if p.callback == nil || interval > progressLogInterval {
+		interval = progressLogInterval
+	}
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastLog := p.start
+		for {
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+
+			progress := p.snapshot()
+			if p.callback != nil {
+				p.callback(progress)
+			}
+
+			if logger != nil && time.Since(lastLog) >= progressLogInterval {
+				lastLog = time.Now()
+				logger.Info(
+					"import progress",
+					"dataset", progress.Dataset,
+					"bytes", progress.BytesRead,
+					"total_bytes", progress.TotalBytes,
+					"rows", progress.Rows,
+					"rejected", progress.Rejected,
+					"rows_per_second", int64(progress.RowsPerSecond),
+					"eta", progress.ETA.Round(time.Second),
+				)
+			}
+		}
+	}()
+}
+
+// Stop ends the reporting and hands the final snapshot to the callback.
+func (p *progressReporter) Stop() {
+	close(p.stop)
+	<-p.done
+
+	if p.callback != nil {
+		progress := p.snapshot()
+		progress.Done = true
+		p.callback(progress)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,57 @@
+package omdb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressReporter(t *testing.T) {
+	var mu sync.Mutex
+	var snapshots []Progress
+
+	options := newImportOptions([]ImportOption{
+		WithProgress(func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			snapshots = append(snapshots, p)
+		}, time.Millisecond),
+	})
+
+	reporter := newProgressReporter("all_movies", 20, options)
+	reporter.Start(context.Background())
+
+	n, err := io.Copy(io.Discard, reporter.Reader(strings.NewReader("0123456789")))
+	require.NoError(t, err)
+	require.EqualValues(t, 10, n)
+	reporter.rows.Add(3)
+
+	time.Sleep(10 * time.Millisecond)
+	reporter.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.NotEmpty(t, snapshots)
+	last := snapshots[len(snapshots)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, "all_movies", last.Dataset)
+	assert.EqualValues(t, 10, last.BytesRead)
+	assert.EqualValues(t, 3, last.Rows)
+	assert.Equal(t, 0.5, last.Fraction())
+	assert.InDelta(t, last.Elapsed, last.ETA, float64(time.Millisecond))
+
+	for _, p := range snapshots[:len(snapshots)-1] {
+		assert.False(t, p.Done)
+	}
+}
+
+func TestProgressUnknownSize(t *testing.T) {
+	p := Progress{BytesRead: 10, TotalBytes: -1}
+	assert.Equal(t, -1.0, p.Fraction())
+}
@@ -0,0 +1,24 @@
+package omdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrErrorBudgetExceeded is returned by a lenient import that rejected more
+// rows than it was allowed to.
+var ErrErrorBudgetExceeded = errors.New("error budget exceeded")
+
+// recordReject stores a row that failed to parse. It deliberately does not
+// use the import transaction, so rejects survive an aborted import.
+func recordReject(ctx context.Context, db *sql.DB, dataset string, lineNumber int, line string, rowErr error) error {
+	const query = "INSERT INTO import_rejects (dataset, line_number, raw_line, error) VALUES ($1, $2, $3, $4)"
+
+	if _, err := db.ExecContext(ctx, query, dataset, lineNumber, line, rowErr.Error()); err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+
+	return nil
+}
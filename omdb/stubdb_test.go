@@ -0,0 +1,95 @@
+package omdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// stubDriver is a database answering every query with a single row holding
+// value, and every statement with no affected rows. It records what it was
+// sent, so tests can check the statements without postgres.
+type stubDriver struct {
+	value driver.Value
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *stubDriver) Open(string) (driver.Conn, error) { return stubConn{d}, nil }
+
+// sent returns the queries and statements sent so far.
+func (d *stubDriver) sent() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.queries...)
+}
+
+type stubConn struct{ d *stubDriver }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, query)
+	c.d.mu.Unlock()
+
+	return stubStmt{c.d}, nil
+}
+func (c stubConn) Close() error              { return nil }
+func (c stubConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct{ d *stubDriver }
+
+func (stubStmt) Close() error  { return nil }
+func (stubStmt) NumInput() int { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &stubRows{value: s.d.value}, nil
+}
+
+type stubRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *stubRows) Columns() []string { return []string{"value"} }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+// stubDrivers counts the drivers registered, to name them apart.
+var stubDrivers atomic.Int32
+
+// stubDB opens a database on a new stubDriver answering queries with value.
+func stubDB(t *testing.T, value driver.Value) (*sql.DB, *stubDriver) {
+	t.Helper()
+
+	d := &stubDriver{value: value}
+	name := fmt.Sprintf("omdb-stub-%d", stubDrivers.Add(1))
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, d
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lsmoura/omdb-api/jobs"
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/robfig/cron/v3"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration written as a string ("5m", "1h30m") in the
+// config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("time.ParseDuration: %w", err)
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+type Entry struct {
+	Dataset string `json:"dataset"`
+	// Schedule is a standard five field cron expression, or a descriptor
+	// such as "@daily".
+	Schedule string      `json:"schedule"`
+	Params   jobs.Params `json:"params"`
+	// Jitter overrides the config wide jitter for this entry.
+	Jitter *Duration `json:"jitter,omitempty"`
+
+	schedule cron.Schedule
+}
+
+type Config struct {
+	// Jitter is the maximum random delay added to every run, so a fleet of
+	// schedulers does not hit omdb at the same second.
+	Jitter   Duration `json:"jitter"`
+	Datasets []Entry  `json:"datasets"`
+}
+
+func (e Entry) jitter(c *Config) time.Duration {
+	if e.Jitter != nil {
+		return time.Duration(*e.Jitter)
+	}
+
+	return time.Duration(c.Jitter)
+}
+
+// Parse reads and validates a JSON config.
+func Parse(b []byte) (*Config, error) {
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if len(c.Datasets) == 0 {
+		return nil, fmt.Errorf("no datasets scheduled")
+	}
+
+	for i := range c.Datasets {
+		e := &c.Datasets[i]
+		if _, ok := omdb.LookupDataset(e.Dataset); !ok {
+			return nil, fmt.Errorf("unknown dataset: %q", e.Dataset)
+		}
+
+		schedule, err := cron.ParseStandard(e.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cron.ParseStandard: %w", e.Dataset, err)
+		}
+		e.schedule = schedule
+	}
+
+	return &c, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	return Parse(b)
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"github.com/lsmoura/omdb-api/omdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLoadExampleConfig(t *testing.T) {
+	c, err := LoadConfig("../scheduler.example.json")
+	require.NoError(t, err)
+
+	require.Len(t, c.Datasets, 2)
+	assert.Equal(t, "all_movies", c.Datasets[0].Dataset)
+	assert.Equal(t, omdb.MissingSoftDelete, c.Datasets[0].Params.MissingPolicy)
+	assert.Equal(t, 5*time.Minute, c.Datasets[0].jitter(c))
+	assert.Equal(t, time.Minute, c.Datasets[1].jitter(c))
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		`{}`,
+		`{"datasets": [{"dataset": "people", "schedule": "@daily"}]}`,
+		`{"datasets": [{"dataset": "all_movies", "schedule": "every day"}]}`,
+		`{"jitter": "soon", "datasets": [{"dataset": "all_movies", "schedule": "@daily"}]}`,
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test))
+		assert.Error(t, err, test)
+	}
+}
+
+func TestNext(t *testing.T) {
+	c, err := Parse([]byte(`{"datasets": [{"dataset": "all_movies", "schedule": "0 3 * * *"}]}`))
+	require.NoError(t, err)
+
+	now := time.Date(2023, 4, 11, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2023, 4, 12, 3, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, want, next(c.Datasets[0], now, 0))
+
+	for i := 0; i < 100; i++ {
+		at := next(c.Datasets[0], now, time.Minute)
+		assert.False(t, at.Before(want))
+		assert.True(t, at.Before(want.Add(time.Minute)))
+	}
+}
@@ -0,0 +1,82 @@
+// Package scheduler runs imports on cron schedules, for deployments that do
+// not rely on Vercel crons.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lsmoura/omdb-api/logging"
+	"github.com/lsmoura/omdb-api/omdb"
+	"golang.org/x/exp/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// next returns when an entry should run after now, jitter included.
+func next(e Entry, now time.Time, jitter time.Duration) time.Time {
+	at := e.schedule.Next(now)
+	if jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+
+	return at
+}
+
+// Run imports each dataset of the config on its schedule until ctx is done.
+// A run that is still going when the next one is due makes that next one be
+// skipped; concurrent imports from other processes are kept out by the
+// import lock.
+func Run(ctx context.Context, db *sql.DB, c *Config) error {
+	logger := logging.LoggerFromContext(ctx)
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, e := range c.Datasets {
+		e := e
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runEntry(ctx, db, e, e.jitter(c), logger.With("dataset", e.Dataset))
+		}()
+	}
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func runEntry(ctx context.Context, db *sql.DB, e Entry, jitter time.Duration, logger *slog.Logger) {
+	dataset, _ := omdb.LookupDataset(e.Dataset)
+
+	for {
+		at := next(e, time.Now(), jitter)
+		logger.Info("import scheduled", "at", at)
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		start := time.Now()
+		logger.Info("import started")
+
+		err := dataset.Import(logging.WithLogger(ctx, logger), db, e.Params.Options()...)
+		switch {
+		case errors.Is(err, omdb.ErrImportRunning):
+			logger.Warn("import skipped", "error", err)
+		case err != nil:
+			logger.Error("import failed", "error", err, "duration", time.Since(start))
+		default:
+			logger.Info("import finished", "duration", time.Since(start))
+		}
+	}
+}